@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"image"
+	"time"
+)
+
+// Shown when no slide in the rotation is eligible to be displayed
+type BlankSlide struct {
+}
+
+func NewBlankSlide() *BlankSlide {
+	sl := new(BlankSlide)
+	return sl
+}
+
+func (sl *BlankSlide) Initialize(ctx context.Context) {
+	// sl.won't ever get called since sl.slide isn't in the main rotation.
+}
+
+func (sl *BlankSlide) Terminate() {
+	// sl.won't ever get called since sl.slide isn't in the main rotation.
+}
+
+func (sl *BlankSlide) GetFrameInterval() time.Duration {
+	return 0
+}
+
+func (sl *BlankSlide) IsEnabled() bool {
+	return true // Always enabled
+}
+
+func (sl *BlankSlide) Draw(img *image.RGBA) {
+
+}
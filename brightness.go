@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Brightness used during the night mode schedule
+type NightMode struct {
+	Schedule   *Schedule
+	Brightness int
+}
+
+// How often brightness is re-evaluated, depending on whether a light
+// sensor is in use. Time-of-day windows only need checking every minute.
+const BRIGHTNESS_SCHEDULE_INTERVAL = 1 * time.Minute
+const BRIGHTNESS_SENSOR_INTERVAL = 5 * time.Second
+
+// Periodically sets the display brightness based on the time of day and,
+// optionally, the light level in the room
+type BrightnessController struct {
+	Display       DimmableDisplay
+	DayBrightness int
+	NightMode     *NightMode
+	Ambient       *AmbientLight
+
+	Current     int
+	SmoothedLux float64
+	Ticker      Ticker
+	Lock        sync.Mutex
+}
+
+func NewBrightnessController(d DimmableDisplay, config *Config) *BrightnessController {
+	b := new(BrightnessController)
+	b.Display = d
+	b.Current = -1
+	b.SmoothedLux = -1
+	b.SetConfig(config)
+	return b
+}
+
+// Keeps the brightness updated until the context is cancelled
+func (b *BrightnessController) Start(ctx context.Context) {
+	b.Update()
+	b.Lock.Lock()
+	t := clock.NewTicker(b.GetInterval())
+	b.Ticker = t
+	b.Lock.Unlock()
+	go func() {
+		for {
+			select {
+			case <-t.C():
+				b.Update()
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Picks up brightness settings from a new config and applies them
+func (b *BrightnessController) SetConfig(config *Config) {
+	b.Lock.Lock()
+	b.DayBrightness = config.Brightness
+	b.NightMode = config.NightMode
+	b.Ambient = config.AmbientLight
+	b.SmoothedLux = -1
+	if b.Ticker != nil {
+		b.Ticker.Reset(b.GetInterval())
+	}
+	b.Lock.Unlock()
+	b.Update()
+}
+
+// Expects the caller to hold the lock
+func (b *BrightnessController) GetInterval() time.Duration {
+	if b.Ambient != nil {
+		return BRIGHTNESS_SENSOR_INTERVAL
+	}
+	return BRIGHTNESS_SCHEDULE_INTERVAL
+}
+
+// Records a light reading sent to the controller, if configured to use them
+func (b *BrightnessController) PushLux(lux float64) error {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if b.Ambient == nil {
+		return fmt.Errorf("no ambient light sensor is configured")
+	}
+	s, ok := b.Ambient.Sensor.(*PushedLightSensor)
+	if !ok {
+		return fmt.Errorf("ambient light sensor does not accept pushed readings")
+	}
+	s.Push(lux)
+	return nil
+}
+
+func (b *BrightnessController) Update() {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+
+	target, fromSensor := b.TargetBrightness(clock.Now())
+	if target == b.Current {
+		return
+	}
+	// Ignore small sensor-driven changes so the display doesn't flicker
+	if fromSensor && b.Current >= 0 && Abs(target-b.Current) < b.Ambient.Hysteresis {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"from":       b.Current,
+		"to":         target,
+		"fromSensor": fromSensor,
+	}).Info("Changing display brightness.")
+	b.Current = target
+	b.Display.SetBrightness(target)
+}
+
+// Returns the brightness to use, and whether it came from the light sensor.
+// Expects the caller to hold the lock.
+func (b *BrightnessController) TargetBrightness(t time.Time) (int, bool) {
+	if b.NightMode != nil && b.NightMode.Schedule.IsActive(t) {
+		return b.NightMode.Brightness, false
+	}
+	if b.Ambient == nil {
+		return b.DayBrightness, false
+	}
+
+	lux, err := b.Ambient.Sensor.ReadLux()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Debug("Could not read ambient light sensor.")
+	} else {
+		b.SmoothedLux = SmoothLux(b.SmoothedLux, lux)
+	}
+
+	// Without any readings, fall back to the fixed brightness
+	if b.SmoothedLux < 0 {
+		return b.DayBrightness, false
+	}
+	return b.Ambient.BrightnessFor(b.SmoothedLux, b.DayBrightness), true
+}
+
+func Abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Returns whatever reading it's given
+type FakeLightSensor struct {
+	Lux float64
+	Err error
+}
+
+func (s *FakeLightSensor) ReadLux() (float64, error) {
+	return s.Lux, s.Err
+}
+
+func TestBrightnessFollowsAmbientLight(t *testing.T) {
+	sensor := &FakeLightSensor{Lux: 1000}
+	d := NewCaptureDisplay()
+	b := NewBrightnessController(d, &Config{
+		Brightness: 100,
+		AmbientLight: &AmbientLight{
+			Sensor:        sensor,
+			DarkLux:       0,
+			BrightLux:     1000,
+			MinBrightness: 10,
+			Hysteresis:    5,
+		},
+	})
+	if d.Brightness != 100 {
+		t.Fatalf("Expected full brightness in a bright room, got %d", d.Brightness)
+	}
+
+	// Readings are smoothed, so one dark reading only moves part of the way
+	sensor.Lux = 0
+	b.Update()
+	if d.Brightness <= 10 || d.Brightness >= 100 {
+		t.Errorf("Expected a smoothed change after one dark reading, got %d", d.Brightness)
+	}
+	for i := 0; i < 50; i++ {
+		b.Update()
+	}
+	if d.Brightness > 15 {
+		t.Errorf("Expected brightness to settle near the minimum, got %d", d.Brightness)
+	}
+
+	// Small changes within the hysteresis band are ignored, larger ones aren't
+	settled := d.Brightness
+	b.SmoothedLux = 0
+	sensor.Lux = 0.2
+	b.Update()
+	if d.Brightness != settled {
+		t.Errorf("Expected a change within the hysteresis band to be ignored, got %d", d.Brightness)
+	}
+	sensor.Lux = 30
+	b.Update()
+	if d.Brightness-settled < 5 {
+		t.Errorf("Expected a change past the hysteresis band to apply, got %d", d.Brightness)
+	}
+
+	// Without readings the last brightness stays put
+	current := d.Brightness
+	sensor.Err = fmt.Errorf("unplugged")
+	b.Update()
+	if d.Brightness != current {
+		t.Errorf("Expected a failed reading to keep the brightness, got %d", d.Brightness)
+	}
+}
+
+func TestPushedLightSensorExpires(t *testing.T) {
+	fake := UseFakeClock(t, time.Date(2021, 12, 20, 10, 30, 0, 0, time.UTC))
+
+	s := NewPushedLightSensor()
+	if _, err := s.ReadLux(); err == nil {
+		t.Error("Expected an error before anything was pushed")
+	}
+	s.Push(42)
+	fake.Advance(PUSHED_LIGHT_MAX_AGE - time.Second)
+	if lux, err := s.ReadLux(); err != nil || lux != 42 {
+		t.Errorf("Got %v, %v, expected the pushed reading", lux, err)
+	}
+	fake.Advance(2 * time.Second)
+	if _, err := s.ReadLux(); err == nil {
+		t.Error("Expected the reading to expire")
+	}
+}
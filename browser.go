@@ -0,0 +1,125 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"image"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// Page that draws the matrix in a browser, fed by the WebSocket endpoint
+//
+//go:embed web/matrix.html
+var matrixPage []byte
+
+// How often the browser is sent the slideshow status between frames
+const BROWSER_STATUS_INTERVAL = 1 * time.Second
+
+// Time allowed for each message to reach the browser
+const BROWSER_WRITE_TIMEOUT = 10 * time.Second
+
+var upgrader = websocket.Upgrader{}
+
+// Sent to the browser for each frame, and periodically with only the status
+type BrowserMessage struct {
+	// Base64 encoded RGB values, row by row. Empty for status updates.
+	Frame               string   `json:"frame,omitempty"`
+	Slide               string   `json:"slide"`
+	Running             bool     `json:"running"`
+	Frozen              bool     `json:"frozen"`
+	SecondsUntilAdvance *float64 `json:"seconds_until_advance"`
+}
+
+func (ctrl *Controller) SendMatrixPage(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(200)
+	res.Write(matrixPage)
+}
+
+// Streams frames and status to a browser until it disconnects
+func (ctrl *Controller) StreamToBrowser(res http.ResponseWriter, req *http.Request) {
+	if ctrl.Preview == nil {
+		ctrl.SendResponse(res, 404, "Preview is not enabled")
+		return
+	}
+	conn, err := upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		// The upgrader has already responded to the client
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Debug("Could not open browser connection.")
+		return
+	}
+	defer conn.Close()
+
+	ch := ctrl.Preview.Subscribe()
+	defer ctrl.Preview.Unsubscribe(ch)
+
+	// Nothing is expected from the browser, but reading is needed to notice
+	// when it goes away
+	closed := make(chan bool)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(BROWSER_STATUS_INTERVAL)
+	defer ticker.Stop()
+
+	log.WithFields(log.Fields{
+		"remote": req.RemoteAddr,
+	}).Info("Browser connected.")
+	frame := ctrl.Preview.GetFrame()
+	for {
+		msg := ctrl.BuildBrowserMessage(frame)
+		conn.SetWriteDeadline(time.Now().Add(BROWSER_WRITE_TIMEOUT))
+		if err := conn.WriteJSON(msg); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Debug("Could not write to browser.")
+			return
+		}
+
+		frame = nil
+		select {
+		case frame = <-ch:
+		case <-ticker.C:
+		case <-closed:
+			log.WithFields(log.Fields{
+				"remote": req.RemoteAddr,
+			}).Info("Browser disconnected.")
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (ctrl *Controller) BuildBrowserMessage(frame *image.RGBA) BrowserMessage {
+	status := ctrl.Slideshow.GetStatus()
+	msg := BrowserMessage{
+		Slide:               status.CurrentSlide,
+		Running:             status.Running,
+		Frozen:              status.Frozen,
+		SecondsUntilAdvance: status.SecondsUntilAdvance,
+	}
+	if frame != nil {
+		rgb := make([]byte, 0, SCREEN_WIDTH*SCREEN_HEIGHT*3)
+		for j := 0; j < SCREEN_HEIGHT; j++ {
+			for i := 0; i < SCREEN_WIDTH; i++ {
+				c := frame.RGBAAt(i, j)
+				rgb = append(rgb, c.R, c.G, c.B)
+			}
+		}
+		msg.Frame = base64.StdEncoding.EncodeToString(rgb)
+	}
+	return msg
+}
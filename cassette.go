@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Transport used by every HTTP client the slides create. Replaced at startup
+// to record or replay responses.
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+func NewHttpClient() *http.Client {
+	return &http.Client{Transport: httpTransport}
+}
+
+type CassetteMode int
+
+const (
+	CASSETTE_RECORD CassetteMode = iota
+	CASSETTE_REPLAY
+)
+
+// Saves each response to a directory as it's fetched, or answers requests
+// from a directory saved earlier, so slides can run without network access.
+// Requests are matched on method and URL only.
+type CassetteTransport struct {
+	Dir  string
+	Mode CassetteMode
+	// Used to make real requests while recording
+	Inner http.RoundTripper
+}
+
+// One saved request and its response. Request headers aren't kept, since
+// some hold API credentials.
+type CassetteEntry struct {
+	Method  string      `json:"method"`
+	Url     string      `json:"url"`
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+func NewCassetteTransport(dir string, mode CassetteMode) *CassetteTransport {
+	t := new(CassetteTransport)
+	t.Dir = dir
+	t.Mode = mode
+	t.Inner = http.DefaultTransport
+	return t
+}
+
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	filename := t.GetFilename(req)
+	if t.Mode == CASSETTE_REPLAY {
+		return t.Replay(req, filename)
+	}
+	return t.Record(req, filename)
+}
+
+func (t *CassetteTransport) Record(req *http.Request, filename string) (*http.Response, error) {
+	// Always ask for the full response, since a 304 saved over it would
+	// leave nothing to replay
+	req = req.Clone(req.Context())
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+
+	res, err := t.Inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// Hand the caller a fresh copy of the body that was read
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
+
+	entry := CassetteEntry{
+		Method:  req.Method,
+		Url:     req.URL.String(),
+		Status:  res.StatusCode,
+		Headers: res.Header,
+		Body:    string(body),
+	}
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(t.Dir, 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filename, b, 0644)
+	}
+	if err != nil {
+		// Recording is a side effect, so don't fail the request over it
+		log.WithFields(log.Fields{
+			"url":   entry.Url,
+			"file":  filename,
+			"error": err,
+		}).Warn("Could not record HTTP response.")
+	} else {
+		log.WithFields(log.Fields{
+			"url":  entry.Url,
+			"file": filename,
+		}).Debug("Recorded HTTP response.")
+	}
+	return res, nil
+}
+
+func (t *CassetteTransport) Replay(req *http.Request, filename string) (*http.Response, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s: %v", req.Method, req.URL, err)
+	}
+	var entry CassetteEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, fmt.Errorf("could not read recorded response %s: %v", filename, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Headers,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(entry.Body))),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
+
+var cassetteUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// Names files after the URL so they're easy to find, plus a hash so long or
+// similar URLs don't collide
+func (t *CassetteTransport) GetFilename(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	name := cassetteUnsafeChars.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(t.Dir, fmt.Sprintf("%s-%x.json", name, sum[:4]))
+}
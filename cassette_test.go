@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(res, `{"path": %q}`, req.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: NewCassetteTransport(dir, CASSETTE_RECORD)}
+	body := GetBody(t, recorder, server.URL+"/forecast?days=2")
+	if body != `{"path": "/forecast"}` {
+		t.Errorf("Recording returned %s", body)
+	}
+
+	// Replaying shouldn't touch the network, so stop the server first
+	server.Close()
+	player := &http.Client{Transport: NewCassetteTransport(dir, CASSETTE_REPLAY)}
+	body = GetBody(t, player, server.URL+"/forecast?days=2")
+	if body != `{"path": "/forecast"}` {
+		t.Errorf("Replay returned %s", body)
+	}
+	if requests != 1 {
+		t.Errorf("Server got %d requests, expected 1", requests)
+	}
+
+	// Different query strings are different requests
+	if _, err := player.Get(server.URL + "/forecast?days=3"); err == nil {
+		t.Error("Expected an error replaying a request that wasn't recorded")
+	}
+}
+
+func GetBody(t *testing.T, client *http.Client, url string) string {
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Got status %d", res.StatusCode)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCassetteRecordsFullResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: NewCassetteTransport(dir, CASSETTE_RECORD)}
+	GetBody(t, recorder, server.URL)
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", `"v1"`)
+	res, err := recorder.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected a conditional request to be recorded in full, got status %d", res.StatusCode)
+	}
+
+	player := &http.Client{Transport: NewCassetteTransport(dir, CASSETTE_REPLAY)}
+	if body := GetBody(t, player, server.URL); body != "ok" {
+		t.Errorf("Replay returned %q", body)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -38,17 +40,23 @@ var TreeDef = [][]int{
 }
 
 type ChristmasSlide struct {
-	XmasDate     time.Time
-	RedrawTicker *time.Ticker
+	XmasDate time.Time
+	// Source of sparkle positions, replaceable to get the same tree every time
+	Rand *rand.Rand
+	// Guards the date and the random source, which isn't safe to share
+	Lock sync.Mutex
 }
 
 func NewChristmasSlide() *ChristmasSlide {
 	sl := new(ChristmasSlide)
+	sl.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
 	return sl
 }
 
-func (sl *ChristmasSlide) Initialize() {
-	t := time.Now()
+func (sl *ChristmasSlide) Initialize(ctx context.Context) {
+	t := clock.Now()
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
 	sl.XmasDate = time.Date(t.Year(), time.December, 25, 0, 0, 0, 0, time.Local)
 }
 
@@ -56,19 +64,20 @@ func (sl *ChristmasSlide) Terminate() {
 
 }
 
-func (sl *ChristmasSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEverySecond(d, sl.Draw)
-}
-
-func (sl *ChristmasSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *ChristmasSlide) GetFrameInterval() time.Duration {
+	return 1 * time.Second
 }
 
 func (sl *ChristmasSlide) IsEnabled() bool {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
 	return sl.DaysUntil(sl.XmasDate) >= 0 && sl.DaysUntil(sl.XmasDate) <= 30
 }
 
 func (sl *ChristmasSlide) Draw(img *image.RGBA) {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+
 	red := color.RGBA{255, 0, 0, 255}
 	green := color.RGBA{0, 255, 0, 255}
 	darkgreen := color.RGBA{0, 128, 0, 255}
@@ -117,8 +126,8 @@ func (sl *ChristmasSlide) Draw(img *image.RGBA) {
 
 func (sl *ChristmasSlide) GetRandomWithinTree() (int, int) {
 	for {
-		x := rand.Intn(21)
-		y := rand.Intn(24) + 1 // Don't select top line
+		x := sl.Rand.Intn(21)
+		y := sl.Rand.Intn(24) + 1 // Don't select top line
 		if x > TreeDef[y][0] && x < TreeDef[y][1] {
 			return x, y
 		}
@@ -126,6 +135,6 @@ func (sl *ChristmasSlide) GetRandomWithinTree() (int, int) {
 }
 
 func (sl *ChristmasSlide) DaysUntil(d time.Time) int {
-	diff := time.Until(d).Hours() / 24.0
+	diff := d.Sub(clock.Now()).Hours() / 24.0
 	return int(math.Ceil(diff))
 }
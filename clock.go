@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source of the current time for anything drawn on the display. Slides read
+// the time through here rather than the time package, so they can be shown
+// as of another time or stepped through deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Equivalent of time.Ticker that works with any Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// The clock used everywhere, normally the system time
+var clock Clock = RealClock{}
+
+type RealClock struct{}
+
+func (c RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c RealClock) NewTicker(d time.Duration) Ticker {
+	return &RealTicker{time.NewTicker(d)}
+}
+
+type RealTicker struct {
+	Ticker *time.Ticker
+}
+
+func (t *RealTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+func (t *RealTicker) Stop() {
+	t.Ticker.Stop()
+}
+
+func (t *RealTicker) Reset(d time.Duration) {
+	t.Ticker.Reset(d)
+}
+
+// Runs at normal speed, but starting from some other time
+type OffsetClock struct {
+	Offset time.Duration
+}
+
+func NewOffsetClock(start time.Time) *OffsetClock {
+	c := new(OffsetClock)
+	c.Offset = start.Sub(time.Now())
+	return c
+}
+
+func (c *OffsetClock) Now() time.Time {
+	return time.Now().Add(c.Offset)
+}
+
+func (c *OffsetClock) NewTicker(d time.Duration) Ticker {
+	return &RealTicker{time.NewTicker(d)}
+}
+
+// Only moves when told to, for stepping through time in tests
+type FakeClock struct {
+	Time    time.Time
+	Tickers []*FakeTicker
+	Lock    sync.Mutex
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	c := new(FakeClock)
+	c.Time = start
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.Time
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	t := new(FakeTicker)
+	t.Clock = c
+	t.Interval = d
+	t.Next = c.Time.Add(d)
+	// Like time.Ticker, holds one tick and drops any more if the reader is slow
+	t.Ch = make(chan time.Time, 1)
+	c.Tickers = append(c.Tickers, t)
+	return t
+}
+
+// Moves the clock forward, firing tickers in order as it passes them
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	end := c.Time.Add(d)
+	for {
+		due := c.DueTickersLocked(end)
+		if len(due) == 0 {
+			break
+		}
+		t := due[0]
+		c.Time = t.Next
+		select {
+		case t.Ch <- c.Time:
+		default:
+		}
+		t.Next = t.Next.Add(t.Interval)
+	}
+	c.Time = end
+}
+
+// Returns running tickers that fire by the given time, soonest first.
+// Expects the caller to hold the lock.
+func (c *FakeClock) DueTickersLocked(end time.Time) []*FakeTicker {
+	var due []*FakeTicker
+	for _, t := range c.Tickers {
+		if !t.Stopped && !t.Next.After(end) {
+			due = append(due, t)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Next.Before(due[j].Next)
+	})
+	return due
+}
+
+type FakeTicker struct {
+	Clock    *FakeClock
+	Interval time.Duration
+	Next     time.Time
+	Stopped  bool
+	Ch       chan time.Time
+}
+
+func (t *FakeTicker) C() <-chan time.Time {
+	return t.Ch
+}
+
+func (t *FakeTicker) Stop() {
+	t.Clock.Lock.Lock()
+	defer t.Clock.Lock.Unlock()
+	t.Stopped = true
+}
+
+func (t *FakeTicker) Reset(d time.Duration) {
+	t.Clock.Lock.Lock()
+	defer t.Clock.Lock.Unlock()
+	t.Stopped = false
+	t.Interval = d
+	t.Next = t.Clock.Time.Add(d)
+}
+
+// Accepts either a full RFC 3339 timestamp or one in local time without a
+// zone, e.g. 2026-12-31T23:59:50
+func ParseClockTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", s, time.Local)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Swaps in a fake clock starting at the given time, putting the previous
+// clock back once the test finishes
+func UseFakeClock(t *testing.T, start time.Time) *FakeClock {
+	t.Helper()
+	fake := NewFakeClock(start)
+	old := clock
+	clock = fake
+	t.Cleanup(func() { clock = old })
+	return fake
+}
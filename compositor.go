@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+)
+
+// How often the compositor checks whether the slide needs a new frame.
+// Slides asking to be drawn more often than this get drawn at this rate.
+const COMPOSITOR_TICK = 50 * time.Millisecond
+
+// Draws whichever slide is being shown. It owns the frame clock, redrawing
+// the slide as often as the slide asks or when it has marked itself dirty,
+// and is the only thing that calls Display.Redraw.
+type Compositor struct {
+	Display Display
+	// Nil until started
+	Ticker Ticker
+
+	// Nil when nothing is being shown
+	Slide Slide
+	// When the slide is next due to be drawn, zero if it's only drawn when
+	// shown or dirty
+	NextFrame time.Time
+	// The slide's latest frame, before any transition is applied
+	SlideFrame *image.RGBA
+	// Last frame sent to the display, where the next transition starts from
+	LastFrame *image.RGBA
+	// Set while moving from the previous slide to this one
+	Transition      Transition
+	TransitionFrom  *image.RGBA
+	TransitionStart time.Time
+	// Held while drawing, so frames can't overlap or arrive out of order
+	Lock sync.Mutex
+}
+
+func NewCompositor(d Display) *Compositor {
+	c := new(Compositor)
+	c.Display = d
+	return c
+}
+
+// Runs the frame clock until the context is cancelled
+func (c *Compositor) Start(ctx context.Context) {
+	t := clock.NewTicker(COMPOSITOR_TICK)
+	c.Lock.Lock()
+	c.Ticker = t
+	c.Lock.Unlock()
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C():
+				c.Tick()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Cuts straight to the given slide; see ShowWithTransition
+func (c *Compositor) Show(sl Slide) {
+	c.ShowWithTransition(sl, Transition{})
+}
+
+// Switches to the given slide, drawing its first frame right away. Nothing
+// more is drawn from the previous slide once this returns. Passing nil stops
+// drawing, leaving the last frame up.
+func (c *Compositor) ShowWithTransition(sl Slide, t Transition) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Slide = sl
+	c.NextFrame = time.Time{}
+	c.EndTransitionLocked()
+	if sl == nil {
+		return
+	}
+	if a, ok := sl.(AnimatedSlide); ok {
+		a.RestartAnimation()
+	}
+	// The first frame covers any changes made before now
+	if ds, ok := sl.(DirtySlide); ok {
+		ds.TakeDirty()
+	}
+
+	now := clock.Now()
+	if !t.IsCut() {
+		c.Transition = t
+		c.TransitionFrom = c.GetFrameLocked()
+		c.TransitionStart = now
+		if c.Ticker != nil {
+			c.Ticker.Reset(TRANSITION_FRAME_INTERVAL)
+		}
+	}
+	c.DrawLocked(now)
+	c.PresentLocked(now)
+}
+
+// Stops drawing and blanks the display
+func (c *Compositor) Clear() {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Slide = nil
+	c.NextFrame = time.Time{}
+	c.EndTransitionLocked()
+	c.LastFrame = NewBlankImage()
+	c.Display.Redraw(c.LastFrame)
+}
+
+// Returns the last frame sent to the display, or a blank one
+func (c *Compositor) GetFrame() *image.RGBA {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.GetFrameLocked()
+}
+
+// Expects the caller to hold the lock
+func (c *Compositor) GetFrameLocked() *image.RGBA {
+	if c.LastFrame == nil {
+		return NewBlankImage()
+	}
+	return c.LastFrame
+}
+
+// Draws a frame if the slide is due for one or has changed, or if a
+// transition is in progress. Called on each tick of the frame clock.
+func (c *Compositor) Tick() {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.Slide == nil {
+		return
+	}
+	now := clock.Now()
+	due := !c.NextFrame.IsZero() && !now.Before(c.NextFrame)
+	dirty := false
+	if ds, ok := c.Slide.(DirtySlide); ok {
+		dirty = ds.TakeDirty()
+	}
+	if due || dirty {
+		c.DrawLocked(now)
+	}
+	if due || dirty || !c.Transition.IsCut() {
+		c.PresentLocked(now)
+	}
+}
+
+// Draws the slide into SlideFrame. Expects the caller to hold the lock.
+func (c *Compositor) DrawLocked(now time.Time) {
+	img := NewBlankImage()
+	c.Slide.Draw(img)
+	c.SlideFrame = img
+
+	interval := c.Slide.GetFrameInterval()
+	if interval <= 0 {
+		c.NextFrame = time.Time{}
+		return
+	}
+	// Frames drawn early because the slide was dirty don't change the
+	// rhythm. Otherwise keep to it, unless drawing has fallen behind.
+	if now.Before(c.NextFrame) {
+		return
+	}
+	c.NextFrame = c.NextFrame.Add(interval)
+	if !c.NextFrame.After(now) {
+		c.NextFrame = now.Add(interval)
+	}
+}
+
+// Sends the slide's frame to the display, blended with the previous slide
+// if a transition is in progress. Expects the caller to hold the lock.
+func (c *Compositor) PresentLocked(now time.Time) {
+	img := c.SlideFrame
+	if !c.Transition.IsCut() {
+		progress := float64(now.Sub(c.TransitionStart)) / float64(c.Transition.Duration)
+		if progress >= 1 {
+			c.EndTransitionLocked()
+		} else {
+			img = c.Transition.Effect(c.TransitionFrom, c.SlideFrame, progress)
+		}
+	}
+	c.LastFrame = img
+	c.Display.Redraw(img)
+}
+
+// Expects the caller to hold the lock
+func (c *Compositor) EndTransitionLocked() {
+	if c.Transition.IsCut() {
+		return
+	}
+	c.Transition = Transition{}
+	c.TransitionFrom = nil
+	if c.Ticker != nil {
+		c.Ticker.Reset(COMPOSITOR_TICK)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+// Fills the screen with one color, redrawing as often as asked
+type SolidSlide struct {
+	FakeSlide
+	DirtyFlag
+	Color    color.RGBA
+	Interval time.Duration
+}
+
+func (sl *SolidSlide) Draw(img *image.RGBA) {
+	DrawBox(img, sl.Color, 0, 0, SCREEN_WIDTH, SCREEN_HEIGHT)
+}
+
+func (sl *SolidSlide) GetFrameInterval() time.Duration {
+	return sl.Interval
+}
+
+func TestCompositorSchedulesFrames(t *testing.T) {
+	startTime, err := ParseClockTime(GOLDEN_DEFAULT_TIME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := UseFakeClock(t, startTime)
+
+	d := NewCaptureDisplay()
+	c := NewCompositor(d)
+	step := func(n int) {
+		for i := 0; i < n; i++ {
+			fake.Advance(COMPOSITOR_TICK)
+			c.Tick()
+		}
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	animated := &SolidSlide{Color: red, Interval: 4 * COMPOSITOR_TICK}
+	c.Show(animated)
+	if d.Frames != 1 || d.Frame.RGBAAt(0, 0) != red {
+		t.Fatalf("Expected the first frame right away, got %d frames", d.Frames)
+	}
+	step(3)
+	if d.Frames != 1 {
+		t.Errorf("Expected no frames before the interval, got %d", d.Frames)
+	}
+	step(5)
+	if d.Frames != 3 {
+		t.Errorf("Expected a frame every interval, got %d", d.Frames)
+	}
+
+	blue := color.RGBA{0, 0, 255, 255}
+	static := &SolidSlide{Color: blue}
+	static.MarkDirty()
+	c.Show(static)
+	step(10)
+	if d.Frames != 4 || d.Frame.RGBAAt(0, 0) != blue {
+		t.Errorf("Expected a static slide to be drawn once, got %d frames", d.Frames)
+	}
+	static.MarkDirty()
+	step(1)
+	if d.Frames != 5 {
+		t.Errorf("Expected a dirty slide to be redrawn, got %d frames", d.Frames)
+	}
+
+	c.Show(nil)
+	step(10)
+	if d.Frames != 5 {
+		t.Errorf("Expected nothing drawn without a slide, got %d frames", d.Frames)
+	}
+	c.Clear()
+	if d.Frames != 6 || d.Frame.RGBAAt(0, 0) != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("Expected clearing to draw a blank frame, got %d frames", d.Frames)
+	}
+}
+
+func TestCompositorTransitions(t *testing.T) {
+	startTime, err := ParseClockTime(GOLDEN_DEFAULT_TIME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := UseFakeClock(t, startTime)
+
+	d := NewCaptureDisplay()
+	c := NewCompositor(d)
+	step := func(n int) {
+		for i := 0; i < n; i++ {
+			fake.Advance(TRANSITION_FRAME_INTERVAL)
+			c.Tick()
+		}
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	c.Show(&SolidSlide{Color: red})
+	fade := Transition{Effect: CrossfadeTransition, Duration: 10 * TRANSITION_FRAME_INTERVAL}
+	c.ShowWithTransition(&SolidSlide{Color: blue}, fade)
+	if d.Frames != 2 || d.Frame.RGBAAt(0, 0) != red {
+		t.Fatalf("Expected the transition to start from the last frame, got %v", d.Frame.RGBAAt(0, 0))
+	}
+
+	step(5)
+	if d.Frames != 7 || d.Frame.RGBAAt(0, 0) != (color.RGBA{128, 0, 128, 255}) {
+		t.Errorf("Expected a frame each step, halfway faded, got %d frames of %v", d.Frames, d.Frame.RGBAAt(0, 0))
+	}
+	step(10)
+	if d.Frames != 12 || d.Frame.RGBAAt(0, 0) != blue {
+		t.Errorf("Expected frames to stop once faded in, got %d frames of %v", d.Frames, d.Frame.RGBAAt(0, 0))
+	}
+}
+
+func TestTransitionEffectEnds(t *testing.T) {
+	from := NewBlankImage()
+	to := NewBlankImage()
+	DrawBox(to, color.RGBA{0, 255, 0, 255}, 0, 0, SCREEN_WIDTH, SCREEN_HEIGHT)
+	for name, effect := range TRANSITION_EFFECTS {
+		frames := Transition{Effect: effect, Duration: time.Second}.Frames(from, to, 3)
+		if !bytes.Equal(frames[0].Pix, from.Pix) {
+			t.Errorf("Expected %s to start from the outgoing frame", name)
+		}
+		if !bytes.Equal(frames[2].Pix, to.Pix) {
+			t.Errorf("Expected %s to end on the incoming frame", name)
+		}
+		if bytes.Equal(frames[1].Pix, from.Pix) || bytes.Equal(frames[1].Pix, to.Pix) {
+			t.Errorf("Expected %s to be partway through in the middle", name)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These are most useful run with -race
+
+// Fetches, draws, and status checks happen on different goroutines in the
+// slideshow, so do them all at once for every slide
+func TestSlidesConcurrently(t *testing.T) {
+	for _, c := range goldenCases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			startTime, err := ParseClockTime(GOLDEN_DEFAULT_TIME)
+			if err != nil {
+				t.Fatal(err)
+			}
+			UseFakeClock(t, startTime)
+
+			client := &http.Client{Transport: &FixtureTransport{T: t, Fixtures: c.Fixtures}}
+			sl := c.Build(client)
+			sl.Initialize(context.Background())
+			defer WaitForFetches(t, sl, 0)
+			defer sl.Terminate()
+
+			var wg sync.WaitGroup
+			run := func(n int, fn func()) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < n; i++ {
+						fn()
+					}
+				}()
+			}
+			run(3, func() { Refetch(sl) })
+			run(3, func() { Refetch(sl) })
+			run(10, func() { sl.Draw(NewBlankImage()) })
+			run(10, func() { sl.Draw(NewBlankImage()) })
+			run(10, func() {
+				sl.IsEnabled()
+				if fs, ok := sl.(FetchingSlide); ok {
+					fs.GetFetchStatus()
+				}
+			})
+			wg.Wait()
+		})
+	}
+}
+
+// Does what the slide's refresh timer would
+func Refetch(sl Slide) {
+	if cs, ok := sl.(*CovidSlide); ok {
+		cs.FetchData(context.Background())
+		return
+	}
+	helpers := GetHttpHelpers(sl)
+	for _, h := range helpers {
+		h.Fetch(context.Background())
+	}
+	if len(helpers) == 0 {
+		// Other slides only work out their dates when initialized
+		sl.Initialize(context.Background())
+	}
+}
+
+// Stands in for a real slide, drawing nothing
+type FakeSlide struct{}
+
+func (sl *FakeSlide) Initialize(ctx context.Context)  {}
+func (sl *FakeSlide) Terminate()                      {}
+func (sl *FakeSlide) Draw(img *image.RGBA)            {}
+func (sl *FakeSlide) GetFrameInterval() time.Duration { return 0 }
+func (sl *FakeSlide) IsEnabled() bool                 { return true }
+
+func BuildFakeSlides(n int) []*SlideEntry {
+	var entries []*SlideEntry
+	for i := 0; i < n; i++ {
+		entries = append(entries, &SlideEntry{
+			Id:     fmt.Sprintf("Fake%d", i),
+			Config: SlideConfig{Type: "Fake", Id: fmt.Sprintf("Fake%d", i)},
+			Slide:  new(FakeSlide),
+		})
+	}
+	return entries
+}
+
+// Answers connection probes, so the slideshow starts without waiting
+func StartProbeServer(t *testing.T) Network {
+	probe := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(probe.Close)
+	return Network{
+		ProbeUrls: []string{probe.URL},
+		MaxWait:   time.Second,
+	}
+}
+
+// The controller, advance timer, reloads, and slide initialization all
+// change the slideshow from their own goroutines
+func TestSlideshowConcurrently(t *testing.T) {
+	config := &Config{
+		AdvanceInterval: time.Millisecond,
+		Slides:          BuildFakeSlides(4),
+		Network:         StartProbeServer(t),
+	}
+	s := NewSlideshow(context.Background(), NewPreviewDisplay(), config)
+	if !s.Start() {
+		t.Fatal("Slideshow didn't start")
+	}
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fn(i)
+			}
+		}()
+	}
+	run(func(i int) { s.Advance() })
+	run(func(i int) { s.GoBack() })
+	run(func(i int) { s.ShowSlide(fmt.Sprintf("Fake%d", i%4)) })
+	run(func(i int) {
+		s.Freeze()
+		s.Unfreeze()
+	})
+	run(func(i int) {
+		s.GetStatus()
+		s.IsRunning()
+		s.IsFrozen()
+	})
+	run(func(i int) {
+		if i%10 == 0 {
+			s.Reload(&Config{
+				AdvanceInterval: time.Millisecond,
+				Slides:          BuildFakeSlides(2 + i%3),
+			})
+		}
+	})
+	wg.Wait()
+
+	if !s.Stop() {
+		t.Error("Slideshow didn't stop")
+	}
+	if s.Stop() {
+		t.Error("Slideshow stopped twice")
+	}
+}
+
+// Records whether it's running, so tests can check nothing was leaked
+type TrackedSlide struct {
+	FakeSlide
+	Running bool
+	Lock    sync.Mutex
+}
+
+func (sl *TrackedSlide) Initialize(ctx context.Context) {
+	// Slow enough for overlapping reloads to both be initializing
+	time.Sleep(10 * time.Millisecond)
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.Running = true
+}
+
+func (sl *TrackedSlide) Terminate() {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.Running = false
+}
+
+func (sl *TrackedSlide) IsRunning() bool {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	return sl.Running
+}
+
+func TestSlideshowOverlappingReloads(t *testing.T) {
+	s := NewSlideshow(context.Background(), NewPreviewDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides:          BuildFakeSlides(1),
+		Network:         StartProbeServer(t),
+	})
+	s.Start()
+	defer s.Stop()
+
+	var all []*TrackedSlide
+	var configs []*Config
+	for i := 0; i < 4; i++ {
+		sl := new(TrackedSlide)
+		all = append(all, sl)
+		id := fmt.Sprintf("Tracked%d", i)
+		configs = append(configs, &Config{
+			AdvanceInterval: time.Minute,
+			Slides: []*SlideEntry{{
+				Id:     id,
+				Config: SlideConfig{Type: "Tracked", Id: id},
+				Slide:  sl,
+			}},
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range configs {
+		wg.Add(1)
+		go func(c *Config) {
+			defer wg.Done()
+			s.Reload(c)
+		}(c)
+	}
+	wg.Wait()
+
+	current := s.GetSlides()[0].Slide
+	for i, sl := range all {
+		if sl != current && sl.IsRunning() {
+			t.Errorf("Slide %d was swapped out by another reload but never terminated", i)
+		}
+	}
+}
@@ -1,18 +1,437 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
-// Provides the customizable options for the slideshow
-func GetConfig() *Config {
-	return &Config{
-		AdvanceInterval: 15 * time.Second,
-		Slides: []Slide{
-			NewTimeSlide(),
-			NewWeatherSlide(),
-			NewMbtaSlide(MBTA_STATION_ID_KENDALL),
-			NewCovidSlide(),
-		},
+// Representation of the config file on disk
+type ConfigFile struct {
+	AdvanceInterval Duration      `json:"advance_interval"`
+	Slides          []SlideConfig `json:"slides"`
+
+	// Display brightness percentage outside of night mode, 100 if unset
+	Brightness   *int                `json:"brightness"`
+	NightMode    *NightModeConfig    `json:"night_mode"`
+	AmbientLight *AmbientLightConfig `json:"ambient_light"`
+	Network      *NetworkConfig      `json:"network"`
+	// How slides change over, cutting straight to the next one if unset
+	Transition *TransitionConfig `json:"transition"`
+}
+
+// Dims or blanks the display during the scheduled times
+type NightModeConfig struct {
+	Schedule ScheduleConfig `json:"schedule"`
+	// Brightness percentage at night, where 0 turns the display off.
+	// Required, so leaving it out can't blank the display by accident.
+	Brightness *int `json:"brightness"`
+}
+
+// Checks made at startup, before slides start fetching data
+type NetworkConfig struct {
+	// URLs that respond when the internet is reachable. Any response counts,
+	// so an endpoint returning 204 is best.
+	ProbeUrls []string `json:"probe_urls"`
+	// How long to wait for a probe to succeed before starting in offline
+	// mode, where slides show saved data until fetches start working.
+	// A minute if unset, and "0s" waits forever as older versions did.
+	MaxWait *Duration `json:"max_wait"`
+	// SNTP servers used to set the system clock, tried in order. A port can
+	// be given as "host:port", otherwise 123 is used.
+	NtpServers []string `json:"ntp_servers"`
+}
+
+// Parameters for a single slide in the config file. Which of the fields are
+// used depends on the slide type; see the factories in slidefactory.go.
+type SlideConfig struct {
+	Type string `json:"type"`
+	// Used to refer to the slide from the controller. Defaults to the type,
+	// with a number appended if the type appears more than once.
+	Id string `json:"id"`
+
+	// How long the slide stays on screen, overriding advance_interval
+	Duration Duration `json:"duration"`
+	// Only show the slide on every Nth pass through the slide list
+	EveryNthCycle int `json:"every_nth_cycle"`
+	// Only show the slide at certain times
+	Schedule ScheduleConfig `json:"schedule"`
+	// How the slide is brought into view, overriding the global transition
+	Transition *TransitionConfig `json:"transition"`
+
+	// How often the slide re-fetches its data, overriding the slide's default
+	RefreshInterval Duration `json:"refresh_interval"`
+	// How long fetched data can still be shown while fetches are failing
+	MaxDataAge Duration `json:"max_data_age"`
+
+	// MbtaSlide
+	StationId string `json:"station_id"`
+	// WeatherSlide
+	NwsOffice  string `json:"nws_office"`
+	NwsStation string `json:"nws_station"`
+	// FlightSlide, as date (e.g. "2020-01-15") to flight (e.g. "AA 1234")
+	Flights map[string]string `json:"flights"`
+	// CountdownSlide
+	Events []CountdownEventConfig `json:"events"`
+	// GlyphTestSlide, either "letters" or "numsym"
+	Test string `json:"test"`
+}
+
+// Effect used between slides; see transition.go
+type TransitionConfig struct {
+	// One of "push", "wipe", "crossfade", "dissolve", or "none" to cut
+	Type string `json:"type"`
+	// How long the effect takes, a few hundred milliseconds if unset
+	Duration Duration `json:"duration"`
+}
+
+// When a slide is eligible to be shown; see schedule.go for the formats
+type ScheduleConfig struct {
+	// Days of the week, e.g. ["mon-fri"] or ["sat", "sun"]
+	Weekdays []string `json:"weekdays"`
+	// Times of day, e.g. ["07:00-10:00", "16:00-19:00"]
+	Times []string `json:"times"`
+	// Dates, e.g. ["12-01..12-31"] yearly or ["2026-06-01..2026-06-07"]
+	Dates []string `json:"dates"`
+}
+
+// A slide in the rotation, along with the config that produced it
+type SlideEntry struct {
+	Id       string
+	Config   SlideConfig
+	Slide    Slide
+	Schedule *Schedule
+	// Overrides the slideshow's transition if set
+	Transition *Transition
+	// Set once Initialize has returned, so the slide has something to show.
+	// Guarded by the slideshow's lock.
+	Ready bool
+	// Set once a reload has taken the slide out of the show, after which it
+	// never becomes ready. Guarded by the slideshow's lock.
+	Removed bool
+	// Cancels the context the slide was initialized with, stopping anything
+	// it started even if it's still initializing. Guarded by the slideshow's
+	// lock.
+	Cancel context.CancelFunc
+}
+
+// Canonical form of the entry's config, used to match up slides across
+// reloads. Slides with identical config are interchangeable.
+func (e *SlideEntry) Key() string {
+	b, err := json.Marshal(e.Config)
+	if err != nil {
+		return e.Config.Type
+	}
+	return string(b)
+}
+
+// How the slide is brought into view, if it doesn't set its own transition
+// then the given one
+func (e *SlideEntry) GetTransition(fallback Transition) Transition {
+	if e.Transition != nil {
+		return *e.Transition
+	}
+	return fallback
+}
+
+type CountdownEventConfig struct {
+	Date  string `json:"date"`
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// Adjusts brightness to the room's light level, outside of night mode
+type AmbientLightConfig struct {
+	// Either "file" to read the level from Path, or "push" to use readings
+	// sent to the controller's /ambient endpoint
+	Sensor string `json:"sensor"`
+	Path   string `json:"path"`
+	// Multiplier converting a raw file reading into lux, 1 if unset
+	Scale float64 `json:"scale"`
+	// Light levels mapped to min_brightness and the regular brightness
+	DarkLux       float64 `json:"dark_lux"`
+	BrightLux     float64 `json:"bright_lux"`
+	MinBrightness int     `json:"min_brightness"`
+	// Smallest change in brightness percentage that will be applied
+	Hysteresis int `json:"hysteresis"`
+}
+
+// Wrapper so durations can be written as strings (e.g. "15s") in the file
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Reads the slideshow config from the given file, building all slides.
+// Any problems with the file are collected and returned as a single error.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file ConfigFile
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+
+	return BuildConfig(&file)
+}
+
+func BuildConfig(file *ConfigFile) (*Config, error) {
+	var problems []string
+
+	if file.AdvanceInterval.Duration <= 0 {
+		problems = append(problems, "advance_interval must be positive")
+	}
+	if len(file.Slides) == 0 {
+		problems = append(problems, "at least one slide is required")
+	}
+
+	config := &Config{
+		AdvanceInterval: file.AdvanceInterval.Duration,
+		Brightness:      100,
+		Network:         DefaultNetwork(),
+	}
+
+	if file.Brightness != nil {
+		config.Brightness = *file.Brightness
+		if config.Brightness < 0 || config.Brightness > 100 {
+			problems = append(problems, "brightness must be between 0 and 100")
+		}
+	}
+
+	if file.NightMode != nil {
+		sch, err := ParseSchedule(file.NightMode.Schedule)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("night_mode schedule: %v", err))
+		} else if sch == nil {
+			problems = append(problems, "night_mode requires a schedule")
+		}
+		brightness := 0
+		if file.NightMode.Brightness == nil {
+			problems = append(problems, "night_mode requires a brightness")
+		} else {
+			brightness = *file.NightMode.Brightness
+			if brightness < 0 || brightness > 100 {
+				problems = append(problems, "night_mode brightness must be between 0 and 100")
+			}
+		}
+		config.NightMode = &NightMode{
+			Schedule:   sch,
+			Brightness: brightness,
+		}
+	}
+	if file.AmbientLight != nil {
+		a, err := BuildAmbientLight(file.AmbientLight)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("ambient_light: %v", err))
+		}
+		config.AmbientLight = a
+	}
+	if file.Network != nil {
+		problems = append(problems, BuildNetwork(file.Network, &config.Network)...)
+	}
+	if file.Transition != nil {
+		t, err := BuildTransition(file.Transition)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("transition: %v", err))
+		}
+		config.Transition = t
+	}
+
+	// Explicit IDs are claimed first so generated ones can avoid them
+	ids := make(map[string]bool)
+	for i, sc := range file.Slides {
+		if sc.Id == "" {
+			continue
+		}
+		if ids[sc.Id] {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): duplicate id %q", i, sc.Type, sc.Id))
+		}
+		if strings.Contains(sc.Id, "/") {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): id must not contain \"/\"", i, sc.Type))
+		}
+		ids[sc.Id] = true
+	}
+
+	for i, sc := range file.Slides {
+		// Rotation settings are checked here rather than by the factories,
+		// since the slideshow rather than the slide relies on them
+		invalid := false
+		if sc.Duration.Duration < 0 {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): duration must not be negative", i, sc.Type))
+			invalid = true
+		}
+		if sc.EveryNthCycle < 0 {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): every_nth_cycle must not be negative", i, sc.Type))
+			invalid = true
+		}
+		if invalid {
+			continue
+		}
+		sl, err := BuildSlide(sc)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): %v", i, sc.Type, err))
+			continue
+		}
+		sch, err := ParseSchedule(sc.Schedule)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("slide %d (%s): schedule: %v", i, sc.Type, err))
+			continue
+		}
+		var tr *Transition
+		if sc.Transition != nil {
+			t, err := BuildTransition(sc.Transition)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("slide %d (%s): transition: %v", i, sc.Type, err))
+				continue
+			}
+			tr = &t
+		}
+		id := sc.Id
+		if id == "" {
+			id = sc.Type
+			for n := 2; ids[id]; n++ {
+				id = fmt.Sprintf("%s-%d", sc.Type, n)
+			}
+			ids[id] = true
+		}
+		config.Slides = append(config.Slides, &SlideEntry{
+			Id:         id,
+			Config:     sc,
+			Slide:      sl,
+			Schedule:   sch,
+			Transition: tr,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return config, nil
+}
+
+func BuildAmbientLight(c *AmbientLightConfig) (*AmbientLight, error) {
+	a := &AmbientLight{
+		DarkLux:       c.DarkLux,
+		BrightLux:     c.BrightLux,
+		MinBrightness: c.MinBrightness,
+		Hysteresis:    c.Hysteresis,
+	}
+
+	switch c.Sensor {
+	case "file":
+		if c.Path == "" {
+			return nil, fmt.Errorf("path is required for a file sensor")
+		}
+		scale := c.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		a.Sensor = NewFileLightSensor(c.Path, scale)
+	case "push":
+		a.Sensor = NewPushedLightSensor()
+	default:
+		return nil, fmt.Errorf("sensor must be \"file\" or \"push\"")
+	}
+
+	if c.DarkLux < 0 || c.BrightLux <= c.DarkLux {
+		return nil, fmt.Errorf("bright_lux must be greater than dark_lux")
+	}
+	if c.MinBrightness < 0 || c.MinBrightness > 100 {
+		return nil, fmt.Errorf("min_brightness must be between 0 and 100")
+	}
+	if c.Hysteresis < 0 {
+		return nil, fmt.Errorf("hysteresis must not be negative")
+	}
+	return a, nil
+}
+
+func BuildTransition(c *TransitionConfig) (Transition, error) {
+	if c.Duration.Duration < 0 {
+		return Transition{}, fmt.Errorf("duration must not be negative")
+	}
+	if c.Type == "none" {
+		return Transition{}, nil
+	}
+	effect, ok := TRANSITION_EFFECTS[c.Type]
+	if !ok {
+		return Transition{}, fmt.Errorf("unknown type %q", c.Type)
+	}
+	d := c.Duration.Duration
+	if d == 0 {
+		d = TRANSITION_DEFAULT_DURATION
+	}
+	return Transition{Effect: effect, Duration: d}, nil
+}
+
+// Used when the config file doesn't name its own. Google's endpoints are
+// blocked on some networks, so these avoid them.
+var DEFAULT_PROBE_URLS = []string{"http://detectportal.firefox.com/success.txt"}
+var DEFAULT_NTP_SERVERS = []string{"pool.ntp.org"}
+
+// The slideshow used to wait forever for a connection. It now starts in
+// offline mode after this long; set max_wait to "0s" for the old behavior.
+const DEFAULT_NETWORK_MAX_WAIT = 1 * time.Minute
+
+func DefaultNetwork() Network {
+	return Network{
+		ProbeUrls:  DEFAULT_PROBE_URLS,
+		MaxWait:    DEFAULT_NETWORK_MAX_WAIT,
+		NtpServers: DEFAULT_NTP_SERVERS,
+	}
+}
+
+// Overrides the defaults with whatever is set in the file. Returns any
+// problems found.
+func BuildNetwork(file *NetworkConfig, n *Network) []string {
+	var problems []string
+	if len(file.ProbeUrls) > 0 {
+		n.ProbeUrls = file.ProbeUrls
+	}
+	for _, u := range n.ProbeUrls {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			problems = append(problems, fmt.Sprintf("network: probe url %q must be an http or https URL", u))
+		}
+	}
+	if file.MaxWait != nil {
+		n.MaxWait = file.MaxWait.Duration
+		if n.MaxWait < 0 {
+			problems = append(problems, "network: max_wait must not be negative")
+		}
+	}
+	if len(file.NtpServers) > 0 {
+		n.NtpServers = file.NtpServers
+	}
+	for _, s := range n.NtpServers {
+		if strings.TrimSpace(s) == "" {
+			problems = append(problems, "network: ntp_servers must not be empty")
+		}
 	}
+	return problems
 }
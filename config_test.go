@@ -0,0 +1,253 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func WriteConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := WriteConfigFile(t, `{
+		"advance_interval": "15s",
+		"slides": [
+			{"type": "TimeSlide"},
+			{"type": "MbtaSlide", "station_id": "place-sstat", "duration": "30s"},
+			{"type": "TimeSlide", "id": "clock"}
+		]
+	}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AdvanceInterval.String() != "15s" {
+		t.Errorf("Got advance interval %v, expected 15s", config.AdvanceInterval)
+	}
+	if config.Brightness != 100 {
+		t.Errorf("Got brightness %d, expected the default of 100", config.Brightness)
+	}
+	var ids []string
+	for _, e := range config.Slides {
+		ids = append(ids, e.Id)
+	}
+	if strings.Join(ids, ",") != "TimeSlide,MbtaSlide,clock" {
+		t.Errorf("Got slide ids %v", ids)
+	}
+	if _, ok := config.Slides[1].Slide.(*MbtaSlide); !ok {
+		t.Errorf("Expected an MbtaSlide, got %T", config.Slides[1].Slide)
+	}
+	if config.Slides[1].Config.Duration.String() != "30s" {
+		t.Errorf("Got duration %v, expected 30s", config.Slides[1].Config.Duration)
+	}
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := WriteConfigFile(t, `{
+		"advance_interval": "15s",
+		"slides": [{"type": "TimeSlide", "staton_id": "typo"}]
+	}`)
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "staton_id") {
+		t.Errorf("Expected an error naming the unknown field, got %v", err)
+	}
+}
+
+func TestBuildConfigGeneratesIds(t *testing.T) {
+	config, err := BuildConfig(&ConfigFile{
+		AdvanceInterval: Duration{15 * time.Second},
+		Slides: []SlideConfig{
+			{Type: "TimeSlide"},
+			// Explicit IDs are claimed before generated ones
+			{Type: "ChristmasSlide", Id: "TimeSlide-2"},
+			{Type: "TimeSlide"},
+			{Type: "TimeSlide"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range config.Slides {
+		ids = append(ids, e.Id)
+	}
+	if strings.Join(ids, ",") != "TimeSlide,TimeSlide-2,TimeSlide-3,TimeSlide-4" {
+		t.Errorf("Got slide ids %v", ids)
+	}
+}
+
+func TestBuildConfigProblems(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Slides  []SlideConfig
+		Problem string
+	}{
+		{
+			Name:    "unknown type",
+			Slides:  []SlideConfig{{Type: "BogusSlide"}},
+			Problem: "slide 0 (BogusSlide): unknown slide type",
+		},
+		{
+			Name:    "missing station",
+			Slides:  []SlideConfig{{Type: "TimeSlide"}, {Type: "MbtaSlide"}},
+			Problem: "slide 1 (MbtaSlide): station_id is required",
+		},
+		{
+			Name:    "missing flights",
+			Slides:  []SlideConfig{{Type: "FlightSlide"}},
+			Problem: "slide 0 (FlightSlide): flights is required",
+		},
+		{
+			Name:    "missing events",
+			Slides:  []SlideConfig{{Type: "CountdownSlide"}},
+			Problem: "slide 0 (CountdownSlide): events is required",
+		},
+		{
+			Name: "missing label",
+			Slides: []SlideConfig{{Type: "CountdownSlide", Events: []CountdownEventConfig{
+				{Date: "2022-01-01"},
+			}}},
+			Problem: "slide 0 (CountdownSlide): event on 2022-01-01 is missing a label",
+		},
+		{
+			Name:    "duplicate id",
+			Slides:  []SlideConfig{{Type: "TimeSlide", Id: "a"}, {Type: "ChristmasSlide", Id: "a"}},
+			Problem: "slide 1 (ChristmasSlide): duplicate id \"a\"",
+		},
+		{
+			Name:    "slash in id",
+			Slides:  []SlideConfig{{Type: "TimeSlide", Id: "a/b"}},
+			Problem: "slide 0 (TimeSlide): id must not contain \"/\"",
+		},
+		{
+			Name:    "bad schedule",
+			Slides:  []SlideConfig{{Type: "TimeSlide", Schedule: ScheduleConfig{Weekdays: []string{"funday"}}}},
+			Problem: "slide 0 (TimeSlide): schedule: unknown weekday \"funday\"",
+		},
+		{
+			Name:    "bad transition",
+			Slides:  []SlideConfig{{Type: "TimeSlide", Transition: &TransitionConfig{Type: "spin"}}},
+			Problem: "slide 0 (TimeSlide): transition: unknown type \"spin\"",
+		},
+		{
+			Name:    "negative duration",
+			Slides:  []SlideConfig{{Type: "TimeSlide", Duration: Duration{-time.Second}}},
+			Problem: "slide 0 (TimeSlide): duration must not be negative",
+		},
+		{
+			Name:    "negative cycle",
+			Slides:  []SlideConfig{{Type: "TimeSlide", EveryNthCycle: -2}},
+			Problem: "slide 0 (TimeSlide): every_nth_cycle must not be negative",
+		},
+		{
+			Name:    "no slides",
+			Problem: "at least one slide is required",
+		},
+	}
+	for _, c := range cases {
+		_, err := BuildConfig(&ConfigFile{
+			AdvanceInterval: Duration{15 * time.Second},
+			Slides:          c.Slides,
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error", c.Name)
+		} else if !strings.Contains(err.Error(), c.Problem) {
+			t.Errorf("%s: expected %q in the error, got %q", c.Name, c.Problem, err)
+		}
+	}
+}
+
+func TestBuildConfigCollectsProblems(t *testing.T) {
+	brightness := 150
+	_, err := BuildConfig(&ConfigFile{
+		Brightness: &brightness,
+		Slides: []SlideConfig{
+			{Type: "BogusSlide"},
+			{Type: "TimeSlide"},
+			{Type: "MbtaSlide"},
+		},
+	})
+	expected := "invalid config: advance_interval must be positive; " +
+		"brightness must be between 0 and 100; " +
+		"slide 0 (BogusSlide): unknown slide type; " +
+		"slide 2 (MbtaSlide): station_id is required"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Got error %q, expected %q", err, expected)
+	}
+}
+
+func TestBuildConfigNetwork(t *testing.T) {
+	slides := []SlideConfig{{Type: "TimeSlide"}}
+	config, err := BuildConfig(&ConfigFile{
+		AdvanceInterval: Duration{15 * time.Second},
+		Slides:          slides,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := config.Network
+	if n.MaxWait != DEFAULT_NETWORK_MAX_WAIT || len(n.ProbeUrls) == 0 || len(n.NtpServers) == 0 {
+		t.Errorf("Expected the default network checks, got %+v", n)
+	}
+
+	forever := Duration{0}
+	config, err = BuildConfig(&ConfigFile{
+		AdvanceInterval: Duration{15 * time.Second},
+		Slides:          slides,
+		Network: &NetworkConfig{
+			ProbeUrls:  []string{"http://192.168.1.1/"},
+			MaxWait:    &forever,
+			NtpServers: []string{"192.168.1.1:1123"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = config.Network
+	if n.MaxWait != 0 || n.ProbeUrls[0] != "http://192.168.1.1/" || n.NtpServers[0] != "192.168.1.1:1123" {
+		t.Errorf("Expected the configured network checks, got %+v", n)
+	}
+
+	_, err = BuildConfig(&ConfigFile{
+		AdvanceInterval: Duration{15 * time.Second},
+		Slides:          slides,
+		Network:         &NetworkConfig{ProbeUrls: []string{"ftp://example.com"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "must be an http or https URL") {
+		t.Errorf("Expected a bad probe URL to be rejected, got %v", err)
+	}
+}
+
+func TestBuildConfigNightMode(t *testing.T) {
+	path := WriteConfigFile(t, `{
+		"advance_interval": "15s",
+		"slides": [{"type": "TimeSlide"}],
+		"night_mode": {"schedule": {"times": ["23:00-06:00"]}}
+	}`)
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "night_mode requires a brightness") {
+		t.Errorf("Expected a missing night brightness to be rejected, got %v", err)
+	}
+
+	// Zero is allowed when it's given, and turns the display off
+	path = WriteConfigFile(t, `{
+		"advance_interval": "15s",
+		"slides": [{"type": "TimeSlide"}],
+		"night_mode": {"schedule": {"times": ["23:00-06:00"]}, "brightness": 0}
+	}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.NightMode == nil || config.NightMode.Brightness != 0 || config.NightMode.Schedule == nil {
+		t.Errorf("Got night mode %+v", config.NightMode)
+	}
+}
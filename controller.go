@@ -1,87 +1,339 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Controller struct {
 	Slideshow  *Slideshow
-	ShutdownCh chan bool
+	Brightness *BrightnessController
+	Preview    *PreviewDisplay
+	// Cancels the context the program runs under, starting an orderly stop
+	Shutdown context.CancelFunc
 }
 
-func NewController(s *Slideshow) *Controller {
+// Size of each LED in rendered preview frames, in image pixels
+const PREVIEW_SCALE = 4
+
+// Boundary between parts of the MJPEG stream
+const PREVIEW_BOUNDARY = "frame"
+
+// How long requests still in progress at shutdown get to finish
+const CONTROLLER_SHUTDOWN_TIMEOUT = 5 * time.Second
+
+func NewController(s *Slideshow, shutdown context.CancelFunc) *Controller {
 	ctrl := new(Controller)
 	ctrl.Slideshow = s
-	ctrl.ShutdownCh = make(chan bool)
+	ctrl.Shutdown = shutdown
 	return ctrl
 }
 
-func (ctrl *Controller) RunUntilShutdown() {
+// Serves requests until the context is cancelled, either by a signal or the
+// /shutdown endpoint
+func (ctrl *Controller) RunUntilShutdown(ctx context.Context) {
+	// Requests share the context, so preview streams end at shutdown too
+	srv := &http.Server{
+		Addr:        ":5000",
+		Handler:     ctrl,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 	go func() {
 		log.Info("Started HTTP controller endpoint.")
-		log.Warn(http.ListenAndServe(":5000", ctrl))
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Warn(err)
+		}
 	}()
 	// This blocks until shutdown signal is received
-	<-ctrl.ShutdownCh
-}
+	<-ctx.Done()
 
-func (ctrl *Controller) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), CONTROLLER_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.WithFields(log.Fields{
-			"endpoint": req.URL.Path,
-			"method":   req.Method,
-		}).Debug("Request with bad method")
-		res.WriteHeader(405)
-		return
+			"error": err,
+		}).Warn("Could not stop HTTP controller endpoint cleanly.")
 	}
+}
 
+func (ctrl *Controller) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	log.WithFields(log.Fields{
 		"endpoint": req.URL.Path,
+		"method":   req.Method,
 	}).Debug("Controller received request")
 
+	// Only jumping to a slide takes a parameter in the path
+	if strings.HasPrefix(req.URL.Path, "/slides/") && strings.HasSuffix(req.URL.Path, "/show") {
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/slides/"), "/show")
+		if !ctrl.Slideshow.IsRunning() {
+			ctrl.SendResponse(res, 409, "Cannot show slide, slideshow is stopped")
+		} else if err := ctrl.Slideshow.ShowSlide(id); err != nil {
+			ctrl.SendResponse(res, 404, "Cannot show slide, "+err.Error())
+		} else {
+			ctrl.SendResponse(res, 200, "Showing slide "+id)
+		}
+		return
+	}
+
 	switch req.URL.Path {
+	case "/status":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.SendJson(res, 200, ctrl.Slideshow.GetStatus())
+		}
+	case "/slides":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.SendJson(res, 200, ctrl.Slideshow.GetSlideStatuses())
+		}
+	case "/":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.SendMatrixPage(res)
+		}
+	case "/ws":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.StreamToBrowser(res, req)
+		}
+	case "/frame.png":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.SendFrame(res)
+		}
+	case "/stream":
+		if ctrl.CheckMethod(res, req, "GET") {
+			ctrl.StreamFrames(res, req)
+		}
+	case "/next":
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.IsRunning() {
+			ctrl.Slideshow.Advance()
+			ctrl.SendResponse(res, 200, "Advanced to next slide")
+		} else {
+			ctrl.SendResponse(res, 409, "Cannot advance, slideshow is stopped")
+		}
+	case "/prev":
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.IsRunning() {
+			ctrl.Slideshow.GoBack()
+			ctrl.SendResponse(res, 200, "Went back to previous slide")
+		} else {
+			ctrl.SendResponse(res, 409, "Cannot go back, slideshow is stopped")
+		}
 	case "/start":
-		if !ctrl.Slideshow.Running {
-			ctrl.Slideshow.Start()
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.Start() {
 			ctrl.SendResponse(res, 200, "Starting slideshow")
 		} else {
-			ctrl.SendResponse(res, 412, "Cannot start, slideshow already running")
+			ctrl.SendResponse(res, 409, "Cannot start, slideshow already running")
 		}
 	case "/stop":
-		if ctrl.Slideshow.Running {
-			ctrl.Slideshow.Stop()
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.Stop() {
 			ctrl.SendResponse(res, 200, "Stopping slideshow")
 		} else {
-			ctrl.SendResponse(res, 412, "Cannot stop, slideshow already stopped")
+			ctrl.SendResponse(res, 409, "Cannot stop, slideshow already stopped")
 		}
 	case "/freeze":
-		if !ctrl.Slideshow.Frozen {
-			ctrl.Slideshow.Freeze()
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.Freeze() {
 			ctrl.SendResponse(res, 200, "Freezing slideshow")
 		} else {
-			ctrl.SendResponse(res, 412, "Cannot freeze, slideshow already frozen")
+			ctrl.SendResponse(res, 409, "Cannot freeze, slideshow already frozen")
 		}
 	case "/unfreeze":
-		if ctrl.Slideshow.Frozen {
-			ctrl.Slideshow.Unfreeze()
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if ctrl.Slideshow.Unfreeze() {
 			ctrl.SendResponse(res, 200, "Unfreezing slideshow")
 		} else {
-			ctrl.SendResponse(res, 412, "Cannot unfreeze, slideshow already unfrozen")
+			ctrl.SendResponse(res, 409, "Cannot unfreeze, slideshow already unfrozen")
+		}
+	case "/reload":
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		if err := ctrl.Reload(); err != nil {
+			ctrl.SendResponse(res, 422, "Cannot reload, config is invalid: "+err.Error())
+		} else {
+			ctrl.SendResponse(res, 200, "Reloaded slideshow config")
+		}
+	case "/ambient":
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
+		lux, err := strconv.ParseFloat(req.URL.Query().Get("lux"), 64)
+		if err != nil || lux < 0 {
+			ctrl.SendResponse(res, 400, "Expected a non-negative lux query parameter")
+		} else if ctrl.Brightness == nil {
+			ctrl.SendResponse(res, 409, "Cannot set ambient light, display is not dimmable")
+		} else if err := ctrl.Brightness.PushLux(lux); err != nil {
+			ctrl.SendResponse(res, 409, "Cannot set ambient light, "+err.Error())
+		} else {
+			ctrl.SendResponse(res, 200, "Recorded ambient light level")
 		}
 	case "/shutdown":
-		ctrl.ShutdownCh <- true
+		if !ctrl.CheckMethod(res, req, "POST") {
+			return
+		}
 		ctrl.SendResponse(res, 200, "Shutting down slideshow controller")
+		ctrl.Shutdown()
 	default:
 		log.WithFields(log.Fields{
 			"endpoint": req.URL.Path,
 		}).Debug("Unknown request type")
-		ctrl.SendResponse(res, 400, "Unknown request type")
+		ctrl.SendResponse(res, 404, "Unknown request type")
+	}
+}
+
+// Rejects the request if it doesn't use the expected method
+func (ctrl *Controller) CheckMethod(res http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+	log.WithFields(log.Fields{
+		"endpoint": req.URL.Path,
+		"method":   req.Method,
+	}).Debug("Request with bad method")
+	res.Header().Set("Allow", method)
+	ctrl.SendResponse(res, 405, "Expected "+method+" request")
+	return false
+}
+
+// Sends the current frame as a PNG drawn to look like the LED matrix
+func (ctrl *Controller) SendFrame(res http.ResponseWriter) {
+	if ctrl.Preview == nil {
+		ctrl.SendResponse(res, 404, "Preview is not enabled")
+		return
 	}
+	frame := ctrl.Preview.GetFrame()
+	if frame == nil {
+		ctrl.SendResponse(res, 503, "Nothing has been drawn yet")
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, RenderLedDots(frame, PREVIEW_SCALE, false).Image()); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("Could not encode preview frame.")
+		res.WriteHeader(500)
+		return
+	}
+	res.Header().Set("Content-Type", "image/png")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(200)
+	res.Write(buf.Bytes())
 }
 
+// Sends each new frame as part of a multipart MJPEG stream, which browsers
+// display in a plain <img> tag. Runs until the client disconnects.
+func (ctrl *Controller) StreamFrames(res http.ResponseWriter, req *http.Request) {
+	if ctrl.Preview == nil {
+		ctrl.SendResponse(res, 404, "Preview is not enabled")
+		return
+	}
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		ctrl.SendResponse(res, 500, "Streaming is not supported")
+		return
+	}
+
+	ch := ctrl.Preview.Subscribe()
+	defer ctrl.Preview.Unsubscribe(ch)
+
+	res.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+PREVIEW_BOUNDARY)
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(200)
+
+	log.Debug("Started preview stream.")
+	frame := ctrl.Preview.GetFrame()
+	for {
+		if frame != nil {
+			var buf bytes.Buffer
+			err := jpeg.Encode(&buf, RenderLedDots(frame, PREVIEW_SCALE, false).Image(), nil)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Warn("Could not encode preview frame.")
+				return
+			}
+			fmt.Fprintf(res, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n",
+				PREVIEW_BOUNDARY, buf.Len())
+			res.Write(buf.Bytes())
+			if _, err := res.Write([]byte("\r\n")); err != nil {
+				log.Debug("Stopped preview stream.")
+				return
+			}
+			flusher.Flush()
+		}
+
+		select {
+		case frame = <-ch:
+		case <-req.Context().Done():
+			log.Debug("Stopped preview stream.")
+			return
+		}
+	}
+}
+
+// Re-reads the config file and applies it to the running slideshow. If the
+// file is invalid, the current config is left in place.
+func (ctrl *Controller) Reload() error {
+	config, err := LoadConfig(*configFlag)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  *configFlag,
+			"error": err,
+		}).Warn("Could not reload config.")
+		return err
+	}
+	ctrl.Slideshow.Reload(config)
+	if ctrl.Brightness != nil {
+		ctrl.Brightness.SetConfig(config)
+	}
+	return nil
+}
+
+// Sends a JSON object with either a message or, for failures, an error
 func (ctrl *Controller) SendResponse(res http.ResponseWriter, code int, message string) {
+	key := "message"
+	if code >= 400 {
+		key = "error"
+	}
+	ctrl.SendJson(res, code, map[string]string{key: message})
+}
+
+func (ctrl *Controller) SendJson(res http.ResponseWriter, code int, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("Could not encode controller response.")
+		res.WriteHeader(500)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(code)
-	res.Write([]byte(message + "\n"))
+	res.Write(append(b, '\n'))
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+// Builds a controller around a slideshow that hasn't been started, so
+// requests only see the state the test sets up
+func NewTestController() *Controller {
+	s := &Slideshow{Slides: BuildFakeSlides(2)}
+	return NewController(s, func() {})
+}
+
+// Sends a request to the controller, returning the status code and the
+// decoded JSON body
+func SendControllerRequest(t *testing.T, ctrl *Controller, method string, path string, body interface{}) int {
+	res := httptest.NewRecorder()
+	ctrl.ServeHTTP(res, httptest.NewRequest(method, path, nil))
+	if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: got content type %q, expected JSON", method, path, ct)
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), body); err != nil {
+		t.Errorf("%s %s: could not decode %q: %v", method, path, res.Body.String(), err)
+	}
+	return res.Code
+}
+
+func TestControllerStatus(t *testing.T) {
+	ctrl := NewTestController()
+	ctrl.Slideshow.Slides[1].Ready = true
+
+	var status SlideshowStatus
+	if code := SendControllerRequest(t, ctrl, "GET", "/status", &status); code != 200 {
+		t.Fatalf("Got status code %d, expected 200", code)
+	}
+	if status.Running || status.Frozen || status.SecondsUntilAdvance != nil || status.TimeSync != nil {
+		t.Errorf("Expected a stopped slideshow that hasn't synced, got %+v", status)
+	}
+	if len(status.Slides) != 2 {
+		t.Fatalf("Got %d slides, expected 2", len(status.Slides))
+	}
+	if status.Slides[0].Id != "Fake0" || status.Slides[0].Type != "Fake" || status.Slides[0].Ready {
+		t.Errorf("Got first slide %+v", status.Slides[0])
+	}
+	if !status.Slides[1].Ready || !status.Slides[1].Enabled || !status.Slides[1].Scheduled {
+		t.Errorf("Expected second slide to be ready, enabled and scheduled, got %+v", status.Slides[1])
+	}
+}
+
+func TestControllerErrors(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Method  string
+		Path    string
+		Running bool
+		Code    int
+		Error   string
+	}{
+		{"unknown path", "GET", "/bogus", false, 404, "Unknown request type"},
+		{"wrong method", "GET", "/next", false, 405, "Expected POST request"},
+		{"wrong method on status", "POST", "/status", false, 405, "Expected GET request"},
+		{"wrong method on slide", "GET", "/slides/Fake0/show", false, 405, "Expected POST request"},
+		{"next while stopped", "POST", "/next", false, 409, "Cannot advance, slideshow is stopped"},
+		{"prev while stopped", "POST", "/prev", false, 409, "Cannot go back, slideshow is stopped"},
+		{"show while stopped", "POST", "/slides/Fake0/show", false, 409, "Cannot show slide, slideshow is stopped"},
+		{"show unknown slide", "POST", "/slides/Nope/show", true, 404, "Cannot show slide, no slide with ID \"Nope\""},
+		{"ambient without lux", "POST", "/ambient", false, 400, "Expected a non-negative lux query parameter"},
+		{"ambient with bad lux", "POST", "/ambient?lux=bright", false, 400, "Expected a non-negative lux query parameter"},
+		{"ambient with negative lux", "POST", "/ambient?lux=-3", false, 400, "Expected a non-negative lux query parameter"},
+		{"ambient without dimming", "POST", "/ambient?lux=30", false, 409, "Cannot set ambient light, display is not dimmable"},
+		{"frame without preview", "GET", "/frame.png", false, 404, "Preview is not enabled"},
+	}
+	for _, c := range cases {
+		ctrl := NewTestController()
+		ctrl.Slideshow.Running = c.Running
+
+		var body map[string]string
+		code := SendControllerRequest(t, ctrl, c.Method, c.Path, &body)
+		if code != c.Code {
+			t.Errorf("%s: got status code %d, expected %d", c.Name, code, c.Code)
+		}
+		if body["error"] != c.Error {
+			t.Errorf("%s: got error %q, expected %q", c.Name, body["error"], c.Error)
+		}
+		if _, ok := body["message"]; ok {
+			t.Errorf("%s: expected no message alongside the error, got %v", c.Name, body)
+		}
+	}
+}
+
+func TestControllerAmbient(t *testing.T) {
+	ctrl := NewTestController()
+	d := NewCaptureDisplay()
+	ctrl.Brightness = NewBrightnessController(d, &Config{Brightness: 100})
+
+	var body map[string]string
+	code := SendControllerRequest(t, ctrl, "POST", "/ambient?lux=30", &body)
+	expected := "Cannot set ambient light, no ambient light sensor is configured"
+	if code != 409 || body["error"] != expected {
+		t.Errorf("Got %d %v, expected 409 with %q", code, body, expected)
+	}
+
+	ctrl.Brightness.SetConfig(&Config{
+		Brightness: 100,
+		AmbientLight: &AmbientLight{
+			Sensor:        NewPushedLightSensor(),
+			BrightLux:     1000,
+			MinBrightness: 10,
+		},
+	})
+	body = nil
+	code = SendControllerRequest(t, ctrl, "POST", "/ambient?lux=30", &body)
+	if code != 200 || body["message"] != "Recorded ambient light level" {
+		t.Errorf("Got %d %v, expected the reading to be recorded", code, body)
+	}
+}
+
+func TestControllerReloadInvalidConfig(t *testing.T) {
+	path := WriteConfigFile(t, `{"advance_interval": "15s", "slides": []}`)
+	defer func(p string) { *configFlag = p }(*configFlag)
+	*configFlag = path
+
+	ctrl := NewTestController()
+	before := ctrl.Slideshow.Slides
+
+	var body map[string]string
+	code := SendControllerRequest(t, ctrl, "POST", "/reload", &body)
+	expected := "Cannot reload, config is invalid: invalid config: at least one slide is required"
+	if code != 422 || body["error"] != expected {
+		t.Errorf("Got %d %v, expected 422 with %q", code, body, expected)
+	}
+	if len(ctrl.Slideshow.Slides) != len(before) || ctrl.Slideshow.Slides[0] != before[0] {
+		t.Error("Expected an invalid config to leave the slides in place")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
@@ -19,7 +20,7 @@ func NewCountdownSlide(events []CountdownEvent) *CountdownSlide {
 	return sl
 }
 
-func (sl *CountdownSlide) Initialize() {
+func (sl *CountdownSlide) Initialize(ctx context.Context) {
 
 }
 
@@ -27,12 +28,8 @@ func (sl *CountdownSlide) Terminate() {
 
 }
 
-func (sl *CountdownSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *CountdownSlide) StopDraw() {
-
+func (sl *CountdownSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *CountdownSlide) IsEnabled() bool {
@@ -40,7 +37,7 @@ func (sl *CountdownSlide) IsEnabled() bool {
 }
 
 func (sl *CountdownSlide) Draw(img *image.RGBA) {
-	today := civil.DateOf(time.Now())
+	today := civil.DateOf(clock.Now())
 	var filteredEvents []CountdownEvent
 	for _, event := range sl.events {
 		if event.date.Before(today) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"image"
@@ -8,6 +9,7 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -24,8 +26,18 @@ type CovidSlide struct {
 	MaData DailyData
 	AzData DailyData
 
-	FetchTicker           *time.Ticker
+	Client        *http.Client
+	FetchInterval time.Duration
+	FetchTicker   Ticker
+	// Cancels the re-fetch loop along with any fetch in progress
+	CancelFetch           context.CancelFunc
 	LastFetchSuccessRatio float64
+	// Marked when new data is fetched, so it's shown straight away
+	DirtyFlag
+	// Guards the data, success ratio, ticker, and cancel func. Fetches work
+	// on copies of the data and swap them in when done, so drawing doesn't
+	// wait on downloads.
+	Lock sync.Mutex
 }
 
 func NewCovidSlide() *CovidSlide {
@@ -33,32 +45,64 @@ func NewCovidSlide() *CovidSlide {
 	sl.UsData = NewDailyData("US")
 	sl.MaData = NewDailyData("Mass")
 	sl.AzData = NewDailyData("Ariz")
+	sl.Client = NewHttpClient()
+	sl.FetchInterval = 4 * time.Hour
 	return sl
 }
 
-func (sl *CovidSlide) Initialize() {
-	// Query for new data once immediately
-	sl.FetchData()
+// Totals saved between runs, since fetching them all takes a while
+type CovidState struct {
+	Us map[civil.Date]int `json:"us"`
+	Ma map[civil.Date]int `json:"ma"`
+	Az map[civil.Date]int `json:"az"`
+}
 
-	// Set up a period re-fetch of the data since it's sometimes late
-	sl.FetchTicker = time.NewTicker(4 * time.Hour)
+const COVID_STATE_KEY = "CovidSlide"
+
+func (sl *CovidSlide) Initialize(ctx context.Context) {
+	// Set up a period re-fetch of the data since it's sometimes late. This
+	// comes first so the slide can be terminated during the first fetch.
+	ctx, cancel := context.WithCancel(ctx)
+	t := clock.NewTicker(sl.FetchInterval)
+	sl.Lock.Lock()
+	sl.FetchTicker = t
+	sl.CancelFetch = cancel
+	sl.Lock.Unlock()
 	go func() {
-		for range sl.FetchTicker.C {
-			sl.FetchData()
+		for {
+			select {
+			case <-t.C():
+				sl.FetchData(ctx)
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
+
+	// Show saved totals right away and update them in the background,
+	// otherwise query for new data once immediately
+	if sl.LoadState() {
+		go sl.FetchData(ctx)
+	} else {
+		sl.FetchData(ctx)
+	}
 }
 
 func (sl *CovidSlide) Terminate() {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	// Nothing to stop if it was never initialized
+	if sl.FetchTicker == nil {
+		return
+	}
 	sl.FetchTicker.Stop()
+	sl.CancelFetch()
+	sl.FetchTicker = nil
+	sl.CancelFetch = nil
 }
 
-func (sl *CovidSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *CovidSlide) StopDraw() {
-
+func (sl *CovidSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *CovidSlide) IsEnabled() bool {
@@ -66,9 +110,22 @@ func (sl *CovidSlide) IsEnabled() bool {
 	return true
 }
 
+func (sl *CovidSlide) GetFetchStatus() FetchStatus {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	// Uses the same threshold as drawing
+	return FetchStatus{
+		Success: sl.LastFetchSuccessRatio >= 0.5,
+	}
+}
+
 func (sl *CovidSlide) Draw(img *image.RGBA) {
-	// Stop immediately if we have too many errors
-	if sl.LastFetchSuccessRatio < 0.5 {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+
+	// Stop immediately if we have too many errors, unless totals saved from
+	// before are enough to go on
+	if sl.LastFetchSuccessRatio < 0.5 && !sl.HasRecentTotalsLocked() {
 		DrawError(img, "Covid Cases", "Missing data.")
 		return
 	}
@@ -82,24 +139,44 @@ func (sl *CovidSlide) Draw(img *image.RGBA) {
 	DrawDataRow(img, 24, sl.AzData, yellow)
 }
 
-func (sl *CovidSlide) FetchData() {
+func (sl *CovidSlide) FetchData(ctx context.Context) {
 	attempted := 0
 	successful := 0
 
+	sl.Lock.Lock()
+	us, ma, az := sl.UsData.Copy(), sl.MaData.Copy(), sl.AzData.Copy()
+	sl.Lock.Unlock()
+
 	// Get data up to 15 days in the past
-	for i := 1; i <= HISTORICAL_COVID_DAYS; i++ {
-		d := civil.DateOf(time.Now().AddDate(0, 0, -i))
+	for i := 1; i <= HISTORICAL_COVID_DAYS && ctx.Err() == nil; i++ {
+		d := civil.DateOf(clock.Now().AddDate(0, 0, -i))
 		// Check if fetch was successful based on data presence
-		_, ok := sl.UsData.Totals[d]
+		_, ok := us.Totals[d]
 		// Refresh if data is 1 or 2 days old, since it might not be stable
 		if !ok || i < 3 {
 			attempted++
-			if sl.QueryForDate(d) {
-				successful++
+			usSum, maSum, azSum, ok := sl.QueryForDate(ctx, d)
+			if !ok {
+				continue
+			}
+			successful++
+			if usSum > 0 {
+				us.Totals[d] = usSum
+			}
+			if maSum > 0 {
+				ma.Totals[d] = maSum
+			}
+			if azSum > 0 {
+				az.Totals[d] = azSum
 			}
 		}
 	}
 
+	// Keep what's already there rather than count the cancelled queries
+	if ctx.Err() != nil {
+		return
+	}
+
 	if attempted < successful {
 		log.WithFields(log.Fields{
 			"attempted":  attempted,
@@ -107,27 +184,96 @@ func (sl *CovidSlide) FetchData() {
 		}).Debug("Some Covid queries failed.")
 	}
 
+	sl.Lock.Lock()
+	sl.UsData = CalculateDiffs(us)
+	sl.MaData = CalculateDiffs(ma)
+	sl.AzData = CalculateDiffs(az)
+	sl.LastFetchSuccessRatio = float64(successful) / float64(attempted)
+	sl.Lock.Unlock()
+	sl.MarkDirty()
+
+	sl.SaveState()
+}
+
+// Restores totals from a previous run. Returns whether there's enough to draw.
+func (sl *CovidSlide) LoadState() bool {
+	var st CovidState
+	if !stateStore.Load(COVID_STATE_KEY, &st) {
+		return false
+	}
+
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	for d, n := range st.Us {
+		sl.UsData.Totals[d] = n
+	}
+	for d, n := range st.Ma {
+		sl.MaData.Totals[d] = n
+	}
+	for d, n := range st.Az {
+		sl.AzData.Totals[d] = n
+	}
 	sl.UsData = CalculateDiffs(sl.UsData)
 	sl.MaData = CalculateDiffs(sl.MaData)
 	sl.AzData = CalculateDiffs(sl.AzData)
 
-	sl.LastFetchSuccessRatio = float64(successful) / float64(attempted)
+	log.WithFields(log.Fields{
+		"days": len(st.Us),
+	}).Info("Loaded saved Covid totals.")
+	return sl.HasRecentTotalsLocked()
+}
+
+func (sl *CovidSlide) SaveState() {
+	sl.Lock.Lock()
+	st := CovidState{
+		Us: RecentTotals(sl.UsData.Totals),
+		Ma: RecentTotals(sl.MaData.Totals),
+		Az: RecentTotals(sl.AzData.Totals),
+	}
+	sl.Lock.Unlock()
+	stateStore.Save(COVID_STATE_KEY, st)
+}
+
+// Drops days too old to be graphed, so saved state doesn't grow forever
+func RecentTotals(totals map[civil.Date]int) map[civil.Date]int {
+	oldest := civil.DateOf(clock.Now().AddDate(0, 0, -HISTORICAL_COVID_DAYS))
+	recent := make(map[civil.Date]int)
+	for d, n := range totals {
+		if !d.Before(oldest) {
+			recent[d] = n
+		}
+	}
+	return recent
+}
+
+// Whether there's a total for yesterday, which is what the slide leads with.
+// Expects the caller to hold the lock.
+func (sl *CovidSlide) HasRecentTotalsLocked() bool {
+	yesterday := civil.DateOf(clock.Now().AddDate(0, 0, -1))
+	_, ok := sl.UsData.Totals[yesterday]
+	return ok
 }
 
 // Can't use HttpHelper since the data doesn't change frequently
-// and we need to do many queries to draw the slide.
-func (sl *CovidSlide) QueryForDate(d civil.Date) bool {
+// and we need to do many queries to draw the slide. Returns the totals for
+// the US, Massachusetts, and Arizona.
+func (sl *CovidSlide) QueryForDate(ctx context.Context, d civil.Date) (int, int, int, bool) {
 	url := fmt.Sprintf("https://raw.githubusercontent.com/CSSEGISandData/COVID-19/master/csse_covid_19_data/csse_covid_19_daily_reports/%02d-%02d-%04d.csv",
 		d.Month, d.Day, d.Year)
 
-	res, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	res, err := sl.Client.Do(req)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"url":   url,
 			"error": err,
 		}).Warn("Response error in Covid query.")
-		return false
+		return 0, 0, 0, false
 	}
+	defer res.Body.Close()
 
 	r := csv.NewReader(res.Body)
 	rows, err := r.ReadAll()
@@ -136,7 +282,7 @@ func (sl *CovidSlide) QueryForDate(d civil.Date) bool {
 			"url":   url,
 			"error": err,
 		}).Warn("Parse error in Covid data.")
-		return false
+		return 0, 0, 0, false
 	}
 
 	// Data is broken down by county so we need to aggregate many rows to
@@ -169,16 +315,7 @@ func (sl *CovidSlide) QueryForDate(d civil.Date) bool {
 		}
 	}
 
-	if usSum > 0 {
-		sl.UsData.Totals[d] = usSum
-	}
-	if maSum > 0 {
-		sl.MaData.Totals[d] = maSum
-	}
-	if azSum > 0 {
-		sl.AzData.Totals[d] = azSum
-	}
-	return true
+	return usSum, maSum, azSum, true
 }
 
 // Below are various helpers used in both Covid and Vaccination slides
@@ -216,11 +353,25 @@ func NewDailyData(label string) DailyData {
 	return d
 }
 
+// Copies the maps too, so the copy can be changed without affecting the
+// original
+func (d DailyData) Copy() DailyData {
+	c := NewDailyData(d.Label)
+	c.Total = d.Total
+	for k, v := range d.Totals {
+		c.Totals[k] = v
+	}
+	for k, v := range d.Diffs {
+		c.Diffs[k] = v
+	}
+	return c
+}
+
 func DrawDataRow(img *image.RGBA, y int, data DailyData, highlight color.RGBA) {
 	white := color.RGBA{255, 255, 255, 255}
 	gray := color.RGBA{128, 128, 128, 255}
 
-	yesterday := civil.DateOf(time.Now().AddDate(0, 0, -1))
+	yesterday := civil.DateOf(clock.Now().AddDate(0, 0, -1))
 
 	WriteString(img, data.Label, white, ALIGN_LEFT, 1, y)
 
@@ -245,13 +396,13 @@ func CalculateDiffs(data DailyData) DailyData {
 
 	for i := -HISTORICAL_COVID_DAYS + 1; i < 0; i++ {
 		// If there was a value for 1 day prior, use that as the last value.
-		dA := civil.DateOf(time.Now().AddDate(0, 0, i-1))
+		dA := civil.DateOf(clock.Now().AddDate(0, 0, i-1))
 		valA, okA := data.Totals[dA]
 		if okA {
 			lastVal = valA
 		}
 
-		dB := civil.DateOf(time.Now().AddDate(0, 0, i))
+		dB := civil.DateOf(clock.Now().AddDate(0, 0, i))
 		valB, okB := data.Totals[dB]
 		// Assuming values continuously increase, we can keep reassigning total.
 		if okB {
@@ -269,7 +420,7 @@ func CalculateDiffs(data DailyData) DailyData {
 func ToDiffsForGraph(diffsByDate map[civil.Date]int) []float64 {
 	var diffs []float64
 	for i := -HISTORICAL_COVID_DAYS + 1; i < 0; i++ {
-		d := civil.DateOf(time.Now().AddDate(0, 0, i))
+		d := civil.DateOf(clock.Now().AddDate(0, 0, i))
 		val, ok := diffsByDate[d]
 		if !ok {
 			val = 0
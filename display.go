@@ -8,3 +8,18 @@ type Display interface {
 	Initialize()
 	Redraw(img *image.RGBA)
 }
+
+// Optionally implemented by displays whose brightness can change at runtime
+type DimmableDisplay interface {
+	Display
+	// Sets brightness as a percentage, where 0 blanks the display entirely
+	SetBrightness(percent int)
+}
+
+// Optionally implemented by displays holding on to a device, which is
+// released at shutdown
+type ClosableDisplay interface {
+	Display
+	// Nothing is drawn after this is called
+	Close()
+}
@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -24,27 +25,23 @@ func NewBlankImage() *image.RGBA {
 	return img
 }
 
-func DrawEverySecond(d Display, drawFn func(*image.RGBA)) *time.Ticker {
-	return DrawEveryInterval(1*time.Second, d, drawFn)
-}
-
-func DrawEveryInterval(interval time.Duration, d Display, drawFn func(*image.RGBA)) *time.Ticker {
-	// First draw right now to avoid lag time
-	DrawOnce(d, drawFn)
-	// Then set up the period redraw
-	t := time.NewTicker(interval)
-	go func() {
-		for range t.C {
-			DrawOnce(d, drawFn)
-		}
-	}()
-	return t
-}
-
-func DrawOnce(d Display, drawFn func(*image.RGBA)) {
-	img := NewBlankImage()
-	drawFn(img)
-	d.Redraw(img)
+// Returns a copy of the image with every color scaled to the given
+// brightness percentage. The image itself is returned at full brightness.
+func ScaleBrightness(img *image.RGBA, percent int) *image.RGBA {
+	if percent >= 100 {
+		return img
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	scaled := image.NewRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		scaled.Pix[i] = uint8(int(img.Pix[i]) * percent / 100)
+		scaled.Pix[i+1] = uint8(int(img.Pix[i+1]) * percent / 100)
+		scaled.Pix[i+2] = uint8(int(img.Pix[i+2]) * percent / 100)
+		scaled.Pix[i+3] = img.Pix[i+3]
+	}
+	return scaled
 }
 
 func WriteString(img *image.RGBA, str string, c color.RGBA, align Alignment, x int, y int) {
@@ -104,15 +101,106 @@ func WriteStringBoxed(img *image.RGBA, str string, c color.RGBA, align Alignment
 }
 
 func WriteGlyph(img *image.RGBA, g Glyph, c color.RGBA, x int, y int) {
+	WriteGlyphClipped(img, g, c, x, y, math.MinInt, math.MaxInt)
+}
+
+// Draws only the parts of the glyph between the given columns, inclusive
+func WriteGlyphClipped(img *image.RGBA, g Glyph, c color.RGBA, x int, y int, minX int, maxX int) {
 	for j, row := range g.Layout {
 		for i, val := range row {
-			if val != 0 {
+			if val != 0 && x+i >= minX && x+i <= maxX {
 				img.SetRGBA(x+int(i), y+int(j), c)
 			}
 		}
 	}
 }
 
+// Text too wide for its box scrolls this many pixels per second, resting at
+// each end before turning around
+const MARQUEE_SPEED = 10
+const MARQUEE_PAUSE = 2 * time.Second
+
+// Scrolls text too wide for its box back and forth, so all of it can be
+// read. Every string drawn through the same marquee scrolls in step from
+// when it was last restarted, so slides restart theirs each time they're
+// shown and redraw at its frame interval while anything is scrolling.
+type Marquee struct {
+	Start time.Time
+	// Guards Start, which is reset while the slide may be drawing
+	Lock sync.Mutex
+}
+
+func NewMarquee() *Marquee {
+	m := new(Marquee)
+	m.Start = clock.Now()
+	return m
+}
+
+func (m *Marquee) Restart() {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+	m.Start = clock.Now()
+}
+
+// Redrawing this often moves scrolling text a pixel at a time
+func (m *Marquee) GetFrameInterval() time.Duration {
+	return time.Second / MARQUEE_SPEED
+}
+
+// Draws like WriteStringBoxed, except text that doesn't fit scrolls within
+// the box instead of being cut off. Returns whether the text is scrolling.
+func (m *Marquee) Draw(img *image.RGBA, str string, c color.RGBA, align Alignment, x int, y int, max int) bool {
+	width := GetDisplayWidth(str)
+	if max <= 0 || width <= max {
+		WriteString(img, str, c, align, x, y)
+		return false
+	}
+
+	var left int
+	switch align {
+	case ALIGN_LEFT:
+		left = x
+	case ALIGN_RIGHT:
+		left = x - max + 1
+	case ALIGN_CENTER:
+		left = x - (max / 2)
+	}
+
+	m.Lock.Lock()
+	elapsed := clock.Now().Sub(m.Start)
+	m.Lock.Unlock()
+	offsetX := left - GetMarqueeOffset(width-max, elapsed)
+	for _, char := range str {
+		g := GetGlyph(char)
+		WriteGlyphClipped(img, g, c, offsetX, y, left, left+max-1)
+		offsetX += g.Width + 1
+	}
+
+	if *debugDraw {
+		aqua := color.RGBA{0, 255, 255, 255}
+		DrawEmptyBox(img, aqua, left, y, max-1, 7)
+	}
+	return true
+}
+
+// How far text that overflows its box by the given number of pixels has
+// scrolled. It rests at the start, scrolls to the end, rests, then scrolls
+// back, over and over.
+func GetMarqueeOffset(overflow int, elapsed time.Duration) int {
+	scroll := time.Duration(overflow) * time.Second / MARQUEE_SPEED
+	t := elapsed % (2 * (MARQUEE_PAUSE + scroll))
+	switch {
+	case t < MARQUEE_PAUSE:
+		return 0
+	case t < MARQUEE_PAUSE+scroll:
+		return int((t - MARQUEE_PAUSE) * MARQUEE_SPEED / time.Second)
+	case t < 2*MARQUEE_PAUSE+scroll:
+		return overflow
+	default:
+		return overflow - int((t-2*MARQUEE_PAUSE-scroll)*MARQUEE_SPEED/time.Second)
+	}
+}
+
 func DrawIcon(img *image.RGBA, iconName string, c color.RGBA, x int, y int) {
 	icon := GetIcon(iconName)
 	for j, row := range icon.Layout {
@@ -156,6 +244,13 @@ func DrawVertLine(img *image.RGBA, c color.RGBA, y1 int, y2 int, x int) {
 	}
 }
 
+// Marks the top right corner to show the data is out of date, because the
+// last attempts to fetch it failed
+func DrawStaleMarker(img *image.RGBA) {
+	orange := color.RGBA{255, 128, 0, 255}
+	img.SetRGBA(SCREEN_WIDTH-1, 0, orange)
+}
+
 func DrawError(img *image.RGBA, slideName, error string) {
 	white := color.RGBA{255, 255, 255, 255}
 	yellow := color.RGBA{255, 255, 0, 255}
@@ -194,7 +289,7 @@ func ColorFromHex(s string) color.RGBA {
 	bStr := s[4:6]
 	b, bErr := hex.DecodeString(bStr)
 	if rErr != nil || gErr != nil || bErr != nil {
-		log.Warn("Error parsing color %s to RGB.", s)
+		log.Warnf("Error parsing color %s to RGB.", s)
 		return color.RGBA{0, 0, 0, 255}
 	}
 	return color.RGBA{r[0], g[0], b[0], 255}
@@ -208,7 +303,7 @@ func ReduceColor(c color.RGBA) color.RGBA {
 			return 0
 		}
 	}
-	return color.RGBA{round(c.R), round(c.G), round(c.B), 0}
+	return color.RGBA{round(c.R), round(c.G), round(c.B), 255}
 }
 
 func GetLeftOfCenterX(img *image.RGBA) int {
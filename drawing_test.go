@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestMarqueeOffset(t *testing.T) {
+	// Scrolling 20 pixels takes two seconds each way
+	cases := []struct {
+		Elapsed time.Duration
+		Offset  int
+	}{
+		{0, 0},
+		{MARQUEE_PAUSE, 0},
+		{MARQUEE_PAUSE + time.Second, 10},
+		{MARQUEE_PAUSE + 2*time.Second, 20},
+		{2*MARQUEE_PAUSE + 2*time.Second - time.Millisecond, 20},
+		{2*MARQUEE_PAUSE + 3*time.Second, 10},
+		{2*MARQUEE_PAUSE + 4*time.Second, 0},
+	}
+	for _, c := range cases {
+		if got := GetMarqueeOffset(20, c.Elapsed); got != c.Offset {
+			t.Errorf("Got offset %d after %v, expected %d", got, c.Elapsed, c.Offset)
+		}
+	}
+}
+
+func TestMarqueeClipsToBox(t *testing.T) {
+	startTime, err := ParseClockTime(GOLDEN_DEFAULT_TIME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := UseFakeClock(t, startTime)
+
+	white := color.RGBA{255, 255, 255, 255}
+	m := NewMarquee()
+	if m.Draw(NewBlankImage(), "SHORT", white, ALIGN_LEFT, 10, 0, 50) {
+		t.Error("Expected text that fits not to scroll")
+	}
+
+	str := "A DESTINATION FAR TOO LONG FOR THE BOX"
+	fake.Advance(MARQUEE_PAUSE + time.Second)
+	img := NewBlankImage()
+	if !m.Draw(img, str, white, ALIGN_LEFT, 10, 0, 50) {
+		t.Fatal("Expected text that doesn't fit to scroll")
+	}
+	for j := 0; j < SCREEN_HEIGHT; j++ {
+		for i := 0; i < SCREEN_WIDTH; i++ {
+			if (i < 10 || i >= 60) && img.RGBAAt(i, j) == white {
+				t.Fatalf("Expected nothing drawn outside the box, found a pixel at (%d, %d)", i, j)
+			}
+		}
+	}
+
+	// Restarting scrolls back to the start
+	m.Restart()
+	restarted := NewBlankImage()
+	m.Draw(restarted, str, white, ALIGN_LEFT, 10, 0, 50)
+	expected := NewBlankImage()
+	WriteStringBoxed(expected, str, white, ALIGN_LEFT, 10, 0, 50)
+	for i := 10; i < 50; i++ {
+		if restarted.RGBAAt(i, 3) != expected.RGBAAt(i, 3) {
+			t.Fatalf("Expected restarted text to start at the beginning, differs at column %d", i)
+		}
+	}
+}
+
+func TestFlightSlideStopsScrollingOnError(t *testing.T) {
+	sl := NewFlightSlide(map[string]string{"2021-12-20": "AA 1234"})
+	sl.Scrolling = true
+	sl.Draw(NewBlankImage())
+	if sl.Scrolling {
+		t.Error("Expected drawing an error to stop the marquee")
+	}
+	if sl.GetFrameInterval() != time.Second {
+		t.Errorf("Got frame interval %v, expected a second", sl.GetFrameInterval())
+	}
+}
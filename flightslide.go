@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"net/http"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -18,9 +20,15 @@ type FlightSlide struct {
 	// Flight currently being displayed/requested
 	ActiveFlight FlightAndDay
 
-	HttpHelper   *HttpHelper
-	RedrawTicker *time.Ticker
-	DisplayData  FlightDisplayData
+	HttpHelper  *HttpHelper
+	DisplayData FlightDisplayData
+	// Scrolls the top line if the flight ID is too long to fit
+	Marquee *Marquee
+	// Whether the top line was scrolling in the last frame
+	Scrolling bool
+	// Guards the active flight, display data, and Scrolling, which are set
+	// while fetching or drawing and read elsewhere
+	Lock sync.Mutex
 }
 
 type FlightAndDay struct {
@@ -36,6 +44,7 @@ func NewFlightSlide(flights map[string]string) *FlightSlide {
 		RequestUrlCallback: sl.BuildRequest,
 		ParseCallback:      sl.Parse,
 	})
+	sl.Marquee = NewMarquee()
 
 	// Copy the flights from the input into a struct (to be used later)
 	// Expected input is date (e.g. "2020-01-15") to flight (e.g AA 1234).
@@ -56,7 +65,7 @@ func NewFlightSlide(flights map[string]string) *FlightSlide {
 	return sl
 }
 
-func (sl *FlightSlide) Initialize() {
+func (sl *FlightSlide) Initialize(ctx context.Context) {
 	// Get the flight to focus on
 	flight, ok := sl.GetActiveFlight()
 	log.WithFields(log.Fields{
@@ -67,22 +76,12 @@ func (sl *FlightSlide) Initialize() {
 	if !ok {
 		return
 	}
+	sl.Lock.Lock()
 	sl.ActiveFlight = flight
-
-	// For development, always read data from file instead of live API call
-	/*f := "flight_sample.json"
-	  data, err := ioutil.ReadFile(f)
-	  if err != nil {
-	      log.WithFields(log.Fields{
-	          "file":  f,
-	          "error": err,
-	      }).Warn("Could not open test JSON.")
-	      return
-	  }
-	  sl.Parse(data)*/
+	sl.Lock.Unlock()
 
 	// Start fetching data
-	sl.HttpHelper.StartLoop()
+	sl.HttpHelper.StartLoop(ctx)
 }
 
 func (sl *FlightSlide) Terminate() {
@@ -90,12 +89,17 @@ func (sl *FlightSlide) Terminate() {
 	sl.HttpHelper.StopLoop()
 }
 
-func (sl *FlightSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEverySecond(d, sl.Draw)
+func (sl *FlightSlide) GetFrameInterval() time.Duration {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	if sl.Scrolling {
+		return sl.Marquee.GetFrameInterval()
+	}
+	return 1 * time.Second
 }
 
-func (sl *FlightSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *FlightSlide) RestartAnimation() {
+	sl.Marquee.Restart()
 }
 
 func (sl *FlightSlide) IsEnabled() bool {
@@ -104,11 +108,18 @@ func (sl *FlightSlide) IsEnabled() bool {
 	return ok
 }
 
-func (sl *FlightSlide) BuildRequest() (*http.Request, error) {
+func (sl *FlightSlide) GetFetchStatus() FetchStatus {
+	return sl.HttpHelper.GetFetchStatus()
+}
+
+func (sl *FlightSlide) BuildRequest(ctx context.Context) (*http.Request, error) {
+	sl.Lock.Lock()
+	id := sl.ActiveFlight.Id
+	sl.Lock.Unlock()
 	url := fmt.Sprintf(
 		"http://flightxml.flightaware.com/json/FlightXML3/FlightInfoStatus?ident=%s&howMany=5",
-		sl.ActiveFlight.Id)
-	req, err := http.NewRequest("GET", url, nil)
+		id)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +146,7 @@ func (sl *FlightSlide) Parse(respBytes []byte) bool {
 		f := respData.Result.Flights[i]
 
 		depDate := civil.DateOf(time.Unix(f.FiledDepartureTime.LocalTime, 0))
-		if depDate == civil.DateOf(time.Now()) {
+		if depDate == civil.DateOf(clock.Now()) {
 			targetFlight = f
 			break
 		}
@@ -144,7 +155,7 @@ func (sl *FlightSlide) Parse(respBytes []byte) bool {
 	// If no flight was found, nothing to display
 	if targetFlight == (FlightInfoStatus{}) {
 		log.Info("No matching flight found in response")
-		sl.DisplayData = displayData
+		sl.SetDisplayData(displayData)
 		return false
 	}
 
@@ -188,13 +199,25 @@ func (sl *FlightSlide) Parse(respBytes []byte) bool {
 
 	log.WithFields(log.Fields{"data": displayData}).Debug("Parsed display data")
 
-	sl.DisplayData = displayData
+	sl.SetDisplayData(displayData)
 	return true
 }
 
+func (sl *FlightSlide) SetDisplayData(data FlightDisplayData) {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.DisplayData = data
+}
+
+func (sl *FlightSlide) GetDisplayData() FlightDisplayData {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	return sl.DisplayData
+}
+
 func (sl *FlightSlide) GetActiveFlight() (FlightAndDay, bool) {
 	for i := range sl.TrackedFlights {
-		if sl.TrackedFlights[i].Date == civil.DateOf(time.Now()) {
+		if sl.TrackedFlights[i].Date == civil.DateOf(clock.Now()) {
 			return sl.TrackedFlights[i], true
 		}
 	}
@@ -213,12 +236,20 @@ func (sl *FlightSlide) GetDurationString(d time.Duration) string {
 }
 
 func (sl *FlightSlide) Draw(img *image.RGBA) {
-	if !sl.HttpHelper.LastFetchSuccess {
+	scrolling := false
+	defer func() {
+		sl.Lock.Lock()
+		sl.Scrolling = scrolling
+		sl.Lock.Unlock()
+	}()
+
+	if !sl.HttpHelper.HasData() {
 		DrawError(img, "Flight Status", "Connection error.")
 		return
 	}
 
-	if sl.DisplayData == (FlightDisplayData{}) {
+	data := sl.GetDisplayData()
+	if data == (FlightDisplayData{}) {
 		DrawError(img, "Flight Status", "No data.")
 		return
 	}
@@ -228,28 +259,28 @@ func (sl *FlightSlide) Draw(img *image.RGBA) {
 	black := color.RGBA{0, 0, 0, 255}
 
 	// Show flight ID on top line
-	WriteString(img, sl.DisplayData.Title, aqua, ALIGN_CENTER, 64, 0)
+	scrolling = sl.Marquee.Draw(img, data.Title, aqua, ALIGN_CENTER, 64, 0, SCREEN_WIDTH)
 
 	// Draw origin/destination boxes on sides
-	ow := GetDisplayWidth(sl.DisplayData.Origin)
+	ow := GetDisplayWidth(data.Origin)
 	DrawBox(img, aqua, 0, 11, ow+4, 9)
-	WriteString(img, sl.DisplayData.Origin, black, ALIGN_LEFT, 2, 12)
+	WriteString(img, data.Origin, black, ALIGN_LEFT, 2, 12)
 
-	dw := GetDisplayWidth(sl.DisplayData.Destination)
+	dw := GetDisplayWidth(data.Destination)
 	DrawBox(img, aqua, 128-dw-4, 11, dw+4, 9)
-	WriteString(img, sl.DisplayData.Destination, black, ALIGN_RIGHT, 125, 12)
+	WriteString(img, data.Destination, black, ALIGN_RIGHT, 125, 12)
 
 	// Timing status
 	status := "On Time"
 	statusColor := color.RGBA{0, 255, 0, 255}
-	if !sl.DisplayData.HasDeparted {
-		if sl.DisplayData.DepartureDelay > 0 {
-			status = fmt.Sprintf("%s Late", sl.GetDurationString(sl.DisplayData.DepartureDelay))
+	if !data.HasDeparted {
+		if data.DepartureDelay > 0 {
+			status = fmt.Sprintf("%s Late", sl.GetDurationString(data.DepartureDelay))
 			statusColor = color.RGBA{255, 255, 0, 255}
 		}
-	} else if !sl.DisplayData.HasArrived {
-		if sl.DisplayData.ArrivalDelay > 0 {
-			status = fmt.Sprintf("%s Late", sl.GetDurationString(sl.DisplayData.ArrivalDelay))
+	} else if !data.HasArrived {
+		if data.ArrivalDelay > 0 {
+			status = fmt.Sprintf("%s Late", sl.GetDurationString(data.ArrivalDelay))
 			statusColor = color.RGBA{255, 255, 0, 255}
 		}
 	} else {
@@ -259,17 +290,21 @@ func (sl *FlightSlide) Draw(img *image.RGBA) {
 
 	// Departure
 	depPrefix := "Dep. "
-	if !sl.DisplayData.HasDeparted {
+	if !data.HasDeparted {
 		depPrefix = "Est. Dep. "
 	}
-	WriteString(img, depPrefix+sl.DisplayData.DepartureTime.Format("3:04 PM"), white, ALIGN_CENTER, 64, 16)
+	WriteString(img, depPrefix+data.DepartureTime.Format("3:04 PM"), white, ALIGN_CENTER, 64, 16)
 
 	// Arrival
 	arrPrefix := "Arr. "
-	if !sl.DisplayData.HasArrived {
+	if !data.HasArrived {
 		arrPrefix = "Est. Arr. "
 	}
-	WriteString(img, arrPrefix+sl.DisplayData.ArrivalTime.Format("3:04 PM"), white, ALIGN_CENTER, 64, 24)
+	WriteString(img, arrPrefix+data.ArrivalTime.Format("3:04 PM"), white, ALIGN_CENTER, 64, 24)
+
+	if sl.HttpHelper.IsStale() {
+		DrawStaleMarker(img)
+	}
 }
 
 // Data structures used by the FlightAware v3 API
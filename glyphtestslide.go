@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"image"
 	"image/color"
+	"time"
 )
 
 type GlyphTestSlide struct {
@@ -22,7 +24,7 @@ func NewGlyphTestSlide(test GlyphTestType) *GlyphTestSlide {
 	return sl
 }
 
-func (sl *GlyphTestSlide) Initialize() {
+func (sl *GlyphTestSlide) Initialize(ctx context.Context) {
 
 }
 
@@ -30,12 +32,8 @@ func (sl *GlyphTestSlide) Terminate() {
 
 }
 
-func (sl *GlyphTestSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *GlyphTestSlide) StopDraw() {
-
+func (sl *GlyphTestSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *GlyphTestSlide) IsEnabled() bool {
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+var updateFlag = flag.Bool("update", false,
+	"If true, rewrites the golden images instead of comparing against them.")
+
+// All fixtures are written as seen from Boston, like the real display
+const GOLDEN_TIME_ZONE = "America/New_York"
+
+// Monday morning a few days before Christmas
+const GOLDEN_DEFAULT_TIME = "2021-12-20T10:30:00"
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	tz, err := time.LoadLocation(GOLDEN_TIME_ZONE)
+	if err != nil {
+		panic(err)
+	}
+	time.Local = tz
+
+	InitGlyphs()
+	InitIcons()
+	InitSlideFactories()
+	os.Exit(m.Run())
+}
+
+type GoldenCase struct {
+	Name string
+	// Time of the first frame, in local time. Defaults to GOLDEN_DEFAULT_TIME.
+	Time string
+	// Maps URL prefixes to files in testdata/fixtures. A file name ending in
+	// a slash is a directory, searched for the last part of the URL path.
+	Fixtures map[string]string
+	// Builds the slide once the clock and HTTP fixtures are in place
+	Build func(client *http.Client) Slide
+	// How long to let the slide run before comparing the latest frame
+	RunFor time.Duration
+}
+
+var goldenCases = []GoldenCase{
+	{
+		Name:  "TimeSlide",
+		Build: func(client *http.Client) Slide { return NewTimeSlide() },
+	},
+	{
+		Name: "WeatherSlide",
+		Fixtures: map[string]string{
+			"https://api.weather.gov/stations/KBOS/observations/latest": "nws_observations.json",
+			"https://api.weather.gov/gridpoints/BOX/69,76/forecast":     "nws_forecast.json",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewWeatherSlide(NWS_OFFICE, NWS_STATION)
+			sl.ObservationsHttpHelper.Client = client
+			sl.ForecastHttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "WeatherSlide-Evening",
+		Time: "2021-12-20T19:15:00",
+		Fixtures: map[string]string{
+			"https://api.weather.gov/stations/KBOS/observations/latest": "nws_observations_evening.json",
+			"https://api.weather.gov/gridpoints/BOX/69,76/forecast":     "nws_forecast_evening.json",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewWeatherSlide(NWS_OFFICE, NWS_STATION)
+			sl.ObservationsHttpHelper.Client = client
+			sl.ForecastHttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "WeatherSlide-NoData",
+		Build: func(client *http.Client) Slide {
+			sl := NewWeatherSlide(NWS_OFFICE, NWS_STATION)
+			sl.ObservationsHttpHelper.Client = client
+			sl.ForecastHttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "MbtaSlide",
+		Fixtures: map[string]string{
+			"https://api-v3.mbta.com/predictions": "mbta_predictions.json",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewMbtaSlide(MBTA_STATION_ID_KENDALL)
+			sl.HttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "MbtaSlide-Later",
+		Fixtures: map[string]string{
+			"https://api-v3.mbta.com/predictions": "mbta_predictions.json",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewMbtaSlide(MBTA_STATION_ID_KENDALL)
+			sl.HttpHelper.Client = client
+			return sl
+		},
+		// Enough for the first few trains to leave
+		RunFor: 4 * time.Minute,
+	},
+	{
+		Name: "FlightSlide",
+		Fixtures: map[string]string{
+			"http://flightxml.flightaware.com/json/FlightXML3/FlightInfoStatus": "flightaware_status.json",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewFlightSlide(map[string]string{"2021-12-20": "B61234"})
+			sl.HttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "CovidSlide",
+		Fixtures: map[string]string{
+			"https://raw.githubusercontent.com/CSSEGISandData/COVID-19/": "jhu/",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewCovidSlide()
+			sl.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "VaccinationSlide",
+		Fixtures: map[string]string{
+			"https://github.com/owid/covid-19-data/": "owid_us_state_vaccinations.csv",
+		},
+		Build: func(client *http.Client) Slide {
+			sl := NewVaccinationSlide()
+			sl.HttpHelper.Client = client
+			return sl
+		},
+	},
+	{
+		Name: "CountdownSlide",
+		Build: func(client *http.Client) Slide {
+			return BuildGoldenSlide(SlideConfig{
+				Type: "CountdownSlide",
+				Events: []CountdownEventConfig{
+					{Date: "2021-12-25", Label: "Christmas", Color: "FF0000"},
+					{Date: "2022-01-01", Label: "New Year"},
+					{Date: "2022-02-14", Label: "Valentine", Color: "FF00FF"},
+				},
+			})
+		},
+	},
+	{
+		Name: "ChristmasSlide",
+		Build: func(client *http.Client) Slide {
+			sl := NewChristmasSlide()
+			sl.Rand = rand.New(rand.NewSource(25))
+			return sl
+		},
+	},
+	{
+		Name:  "NewYearSlide",
+		Time:  "2021-12-31T23:59:50",
+		Build: func(client *http.Client) Slide { return NewNewYearSlide() },
+		// Far enough in for the fireworks to burst
+		RunFor: 3 * time.Second,
+	},
+	{
+		Name:  "StayHomeSlide",
+		Build: func(client *http.Client) Slide { return NewStayHomeSlide() },
+	},
+	{
+		Name:  "GlyphTestSlide-Letters",
+		Build: func(client *http.Client) Slide { return NewGlyphTestSlide(TEST_LETTERS) },
+	},
+	{
+		Name:  "GlyphTestSlide-NumSym",
+		Build: func(client *http.Client) Slide { return NewGlyphTestSlide(TEST_NUMSYM) },
+	},
+	{
+		Name:  "WelcomeSlide",
+		Build: func(client *http.Client) Slide { return NewWelcomeSlide() },
+	},
+}
+
+func TestGoldenImages(t *testing.T) {
+	for _, c := range goldenCases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			got := RenderGoldenCase(t, c)
+			CompareGolden(t, c.Name, got)
+		})
+	}
+}
+
+func BuildGoldenSlide(c SlideConfig) Slide {
+	sl, err := BuildSlide(c)
+	if err != nil {
+		panic(err)
+	}
+	return sl
+}
+
+// Runs the slide against a fake clock and canned HTTP responses, returning
+// the last frame it drew
+func RenderGoldenCase(t *testing.T, c GoldenCase) *image.RGBA {
+	start := GOLDEN_DEFAULT_TIME
+	if c.Time != "" {
+		start = c.Time
+	}
+	startTime, err := ParseClockTime(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := UseFakeClock(t, startTime)
+
+	client := &http.Client{Transport: &FixtureTransport{T: t, Fixtures: c.Fixtures}}
+	sl := c.Build(client)
+	sl.Initialize(context.Background())
+	defer WaitForFetches(t, sl, 0)
+	defer sl.Terminate()
+
+	d := NewCaptureDisplay()
+	comp := NewCompositor(d)
+	comp.Show(sl)
+
+	// Step the clock a tick at a time, so animations see every frame. Any
+	// refreshes that come due finish before the next frame is drawn.
+	for elapsed := COMPOSITOR_TICK; elapsed <= c.RunFor; elapsed += COMPOSITOR_TICK {
+		fake.Advance(COMPOSITOR_TICK)
+		WaitForFetches(t, sl, elapsed)
+		comp.Tick()
+	}
+	return d.Frame
+}
+
+func GetHttpHelpers(sl Slide) []*HttpHelper {
+	switch s := sl.(type) {
+	case *WeatherSlide:
+		return []*HttpHelper{s.ObservationsHttpHelper, s.ForecastHttpHelper}
+	case *MbtaSlide:
+		return []*HttpHelper{s.HttpHelper}
+	case *FlightSlide:
+		return []*HttpHelper{s.HttpHelper}
+	case *VaccinationSlide:
+		return []*HttpHelper{s.HttpHelper}
+	}
+	return nil
+}
+
+// Waits for the refreshes due in the given time since the slide was
+// initialized, then for any fetch still in progress. Refreshes check that the
+// slide hasn't been terminated before fetching, so once that's done after
+// terminating, nothing else will use the fake clock.
+func WaitForFetches(t *testing.T, sl Slide, elapsed time.Duration) {
+	for _, h := range GetHttpHelpers(sl) {
+		due := int(elapsed / h.Config.RefreshInterval)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			h.Lock.Lock()
+			done := h.Refreshes >= due
+			h.Lock.Unlock()
+			if done {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s to refresh", h.Config.SlideId)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		h.FetchLock.Lock()
+		h.FetchLock.Unlock()
+	}
+}
+
+// Compares against testdata/golden/<name>.png, or rewrites it with -update.
+// On a mismatch, writes an image to testdata/diff showing what changed.
+func CompareGolden(t *testing.T, name string, got *image.RGBA) {
+	goldenFile := filepath.Join("testdata", "golden", name+".png")
+	if *updateFlag {
+		if err := WritePng(goldenFile, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(goldenFile)
+	if err != nil {
+		t.Fatalf("Could not open golden image, run with -update to create it: %v", err)
+	}
+	defer f.Close()
+	decoded, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.NewRGBA(decoded.Bounds())
+	draw.Draw(want, want.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("Image is %v, golden image is %v", got.Bounds(), want.Bounds())
+	}
+	diff, n := DiffImages(want, got)
+	if n == 0 {
+		return
+	}
+	diffFile := filepath.Join("testdata", "diff", name+".png")
+	if err := WritePng(diffFile, diff); err != nil {
+		t.Fatal(err)
+	}
+	t.Errorf("%d pixels differ from %s, see %s (golden, actual, and changes from top to bottom)",
+		n, goldenFile, diffFile)
+}
+
+// Stacks the golden image, the actual image, and a map of differing pixels
+// in red, with a gray line between each. Returns the number of differences.
+func DiffImages(want, got *image.RGBA) (*image.RGBA, int) {
+	w := want.Bounds().Dx()
+	h := want.Bounds().Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h*3+2))
+	gray := color.RGBA{64, 64, 64, 255}
+	DrawHorizLine(out, gray, 0, w-1, h)
+	DrawHorizLine(out, gray, 0, w-1, h*2+1)
+	draw.Draw(out, image.Rect(0, 0, w, h), want, image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(0, h+1, w, h*2+1), got, image.Point{}, draw.Src)
+
+	red := color.RGBA{255, 0, 0, 255}
+	n := 0
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			if want.RGBAAt(i, j) != got.RGBAAt(i, j) {
+				out.SetRGBA(i, h*2+2+j, red)
+				n++
+			} else {
+				out.SetRGBA(i, h*2+2+j, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+	return out, n
+}
+
+func WritePng(filename string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// Keeps the latest frame in memory instead of showing it, counting how
+// many have been drawn
+type CaptureDisplay struct {
+	Frame      *image.RGBA
+	Frames     int
+	Brightness int
+}
+
+func NewCaptureDisplay() *CaptureDisplay {
+	d := new(CaptureDisplay)
+	return d
+}
+
+func (d *CaptureDisplay) Initialize() {
+
+}
+
+func (d *CaptureDisplay) Redraw(img *image.RGBA) {
+	d.Frame = img
+	d.Frames++
+}
+
+func (d *CaptureDisplay) SetBrightness(percent int) {
+	d.Brightness = percent
+}
+
+// Answers requests from files in testdata/fixtures. Anything without a
+// fixture gets a 404.
+type FixtureTransport struct {
+	T        *testing.T
+	Fixtures map[string]string
+}
+
+func (f *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	for prefix, file := range f.Fixtures {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
+		if strings.HasSuffix(file, "/") {
+			file += path.Base(req.URL.Path)
+		}
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "fixtures", file))
+		if err != nil {
+			break
+		}
+		return FixtureResponse(req, 200, body), nil
+	}
+	f.T.Logf("No fixture for %s", url)
+	return FixtureResponse(req, 404, []byte("Not Found")), nil
+}
+
+func FixtureResponse(req *http.Request, code int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
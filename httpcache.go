@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The last response that parsed successfully, with what's needed to ask the
+// server whether it has changed
+type HttpCacheEntry struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// The server said the response won't change before this time
+	Expires time.Time `json:"expires"`
+	// When the response was last known to be current
+	Time time.Time `json:"time"`
+	Body string    `json:"body"`
+	// Set by Cache-Control: no-store, never saved
+	NoStore bool `json:"-"`
+}
+
+func NewHttpCacheEntry(url string, res *http.Response, body []byte) HttpCacheEntry {
+	e := HttpCacheEntry{
+		Url:          url,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Body:         string(body),
+	}
+	e.Refresh(res)
+	return e
+}
+
+// Updates the freshness of the entry from a 200 or 304 response
+func (e *HttpCacheEntry) Refresh(res *http.Response) {
+	now := clock.Now()
+	e.Time = now
+	e.Expires = time.Time{}
+	e.NoStore = false
+
+	// Cache-Control takes priority over Expires
+	cc := res.Header.Get("Cache-Control")
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "no-store":
+			e.NoStore = true
+			return
+		case d == "no-cache":
+			return
+		case strings.HasPrefix(d, "max-age="):
+			secs, err := strconv.Atoi(strings.TrimPrefix(d, "max-age="))
+			if err != nil {
+				continue
+			}
+			// Time already spent in other caches counts against it
+			age, _ := strconv.Atoi(res.Header.Get("Age"))
+			e.Expires = now.Add(time.Duration(secs-age) * time.Second)
+			return
+		}
+	}
+
+	if exp := res.Header.Get("Expires"); exp != "" {
+		expTime, err := http.ParseTime(exp)
+		if err != nil {
+			// Invalid values like "0" mean it's already expired
+			return
+		}
+		// Measure from the server's clock in case ours is off
+		if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+			e.Expires = now.Add(expTime.Sub(date))
+		} else {
+			e.Expires = expTime
+		}
+	}
+}
+
+// Whether the server said the response is still current
+func (e *HttpCacheEntry) IsFresh() bool {
+	return clock.Now().Before(e.Expires)
+}
+
+// Adds headers so the server can answer 304 if nothing has changed
+func (e *HttpCacheEntry) AddConditionalHeaders(req *http.Request) {
+	if e.Url != req.URL.String() {
+		return
+	}
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// Slides like weather can be set up more than once with different URLs, so
+// the key covers both
+func GetHttpCacheKey(slideId, url string) string {
+	sum := sha1.Sum([]byte(url))
+	return fmt.Sprintf("%s-%x", slideId, sum[:4])
+}
+
+func LoadHttpCacheEntry(slideId, url string) (HttpCacheEntry, bool) {
+	var e HttpCacheEntry
+	if !stateStore.Load(GetHttpCacheKey(slideId, url), &e) || e.Url != url {
+		return HttpCacheEntry{}, false
+	}
+	return e, true
+}
+
+func SaveHttpCacheEntry(slideId string, e HttpCacheEntry) {
+	if e.NoStore {
+		return
+	}
+	stateStore.Save(GetHttpCacheKey(slideId, e.Url), e)
+}
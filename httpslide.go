@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -15,26 +18,63 @@ type HttpConfig struct {
 	SlideId            string
 	RefreshInterval    time.Duration
 	RequestUrl         string
-	RequestUrlCallback func() (*http.Request, error)
+	RequestUrlCallback func(ctx context.Context) (*http.Request, error)
 	ParseCallback      func([]byte) bool
+	// How long data can be shown after the last successful fetch. Defaults
+	// to a few refresh intervals.
+	MaxDataAge time.Duration
 }
 
 type HttpHelper struct {
-	Config           HttpConfig
-	LastFetchSuccess bool
-	Client           *http.Client
-	RefreshTicker    *time.Ticker
+	Config HttpConfig
+	Client *http.Client
+
+	// Whether the most recent fetch worked, even if older data is still usable
+	LastFetchSuccess    bool
+	LastSuccessTime     time.Time
+	ConsecutiveFailures int
+	RefreshTicker       Ticker
+	// Cancels the refresh loop along with any fetch it has in progress
+	CancelLoop context.CancelFunc
+	// Last response that parsed, used for conditional requests
+	Cache HttpCacheEntry
+	// Number of refreshes finished, so tests can wait for the loop to
+	// catch up with a fake clock
+	Refreshes int
+	// Guards the fields above, which are written by the refresh goroutine
+	// and read while drawing. ParseCallback is never called with it held.
+	Lock sync.Mutex
+	// Keeps the initial fetch and refreshes from overlapping
+	FetchLock sync.Mutex
 }
 
+// Each fetch makes up to this many requests before giving up until the next
+// refresh. The wait between them doubles each time, starting from the base.
+const HTTP_RETRY_ATTEMPTS = 3
+
+var HTTP_RETRY_BASE_DELAY = 2 * time.Second
+
+// Refresh intervals that can be missed before data is considered too old
+const HTTP_DEFAULT_MAX_AGE_INTERVALS = 3
+
 func NewHttpHelper(config HttpConfig) *HttpHelper {
 	h := new(HttpHelper)
 	h.Config = config
-	h.Client = &http.Client{}
+	h.Client = NewHttpClient()
 	return h
 }
 
-func (h *HttpHelper) StartLoop() {
+// Fetches now and then every refresh interval, until stopped or the context
+// is cancelled
+func (h *HttpHelper) StartLoop(ctx context.Context) {
+	// Stopped before getting here, e.g. while another helper on the same
+	// slide was fetching, so leave things as they are for the next start
+	if ctx.Err() != nil {
+		return
+	}
+	h.Lock.Lock()
 	if h.RefreshTicker != nil {
+		h.Lock.Unlock()
 		log.WithFields(log.Fields{
 			"slide": h.Config.SlideId,
 		}).Warn("Attempting to start HTTP loop when already started.")
@@ -42,61 +82,285 @@ func (h *HttpHelper) StartLoop() {
 	}
 
 	// Set up period refresh of the data
-	h.RefreshTicker = time.NewTicker(h.Config.RefreshInterval)
+	// The goroutine keeps its own references since StopLoop clears the fields
+	ctx, cancel := context.WithCancel(ctx)
+	t := clock.NewTicker(h.Config.RefreshInterval)
+	h.RefreshTicker = t
+	h.CancelLoop = cancel
+	h.Lock.Unlock()
 	go func() {
-		for range h.RefreshTicker.C {
-			h.Fetch()
+		// However the loop ends, the helper can be started again afterwards
+		defer h.ClearLoop(t)
+		for {
+			select {
+			case <-t.C():
+				h.Refresh(ctx)
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
-	// Get the data once now (synchronously)
-	h.Fetch()
+	// Show saved data straight away if there is some, and refresh it in the
+	// background. Otherwise get the data once now (synchronously).
+	if h.LoadCache(ctx) {
+		go h.Refresh(ctx)
+		return
+	}
+	h.Fetch(ctx)
+}
+
+// Fetches for the refresh loop, unless the loop has been stopped since
+func (h *HttpHelper) Refresh(ctx context.Context) {
+	h.FetchLock.Lock()
+	defer h.FetchLock.Unlock()
+	if ctx.Err() == nil {
+		h.FetchLocked(ctx)
+	}
+	h.Lock.Lock()
+	h.Refreshes++
+	h.Lock.Unlock()
+}
+
+// Parses the response saved on disk by a previous run, if it's for the same
+// request. Returns whether there's now data to show.
+func (h *HttpHelper) LoadCache(ctx context.Context) bool {
+	req, err := h.BuildRequest(ctx)
+	if err != nil {
+		return false
+	}
+	e, ok := LoadHttpCacheEntry(h.Config.SlideId, req.URL.String())
+	if !ok || !h.Config.ParseCallback([]byte(e.Body)) {
+		return false
+	}
+	log.WithFields(log.Fields{
+		"slide": h.Config.SlideId,
+		"time":  e.Time,
+	}).Info("Loaded cached HTTP response.")
+
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+	h.Cache = e
+	h.LastFetchSuccess = true
+	h.LastSuccessTime = e.Time
+	return h.HasDataLocked()
 }
 
 func (h *HttpHelper) StopLoop() {
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
 	if h.RefreshTicker == nil {
+		// Cancelling the context passed to StartLoop also stops the loop
 		log.WithFields(log.Fields{
 			"slide": h.Config.SlideId,
-		}).Warn("Attempting to stop HTTP loop when already stopped.")
+		}).Debug("HTTP loop already stopped.")
 		return
 	}
 	h.RefreshTicker.Stop()
 	h.RefreshTicker = nil
+	h.CancelLoop()
+	h.CancelLoop = nil
+}
+
+// Forgets the loop using the given ticker, unless it's already been replaced
+// by a new one
+func (h *HttpHelper) ClearLoop(t Ticker) {
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+	if h.RefreshTicker != t {
+		return
+	}
+	t.Stop()
+	h.RefreshTicker = nil
+	h.CancelLoop()
+	h.CancelLoop = nil
+}
+
+func (h *HttpHelper) GetFetchStatus() FetchStatus {
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+	st := FetchStatus{
+		Success:             h.LastFetchSuccess,
+		ConsecutiveFailures: h.ConsecutiveFailures,
+		Stale:               h.IsStaleLocked(),
+	}
+	if !h.LastSuccessTime.IsZero() {
+		t := h.LastSuccessTime
+		st.LastSuccess = &t
+	}
+	return st
+}
+
+func (h *HttpHelper) GetMaxDataAge() time.Duration {
+	if h.Config.MaxDataAge > 0 {
+		return h.Config.MaxDataAge
+	}
+	return HTTP_DEFAULT_MAX_AGE_INTERVALS * h.Config.RefreshInterval
+}
+
+// Whether there's data recent enough to show, even if the last fetch failed
+func (h *HttpHelper) HasData() bool {
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+	return h.HasDataLocked()
+}
+
+// Same as HasData, but expects the caller to hold the lock
+func (h *HttpHelper) HasDataLocked() bool {
+	if h.LastSuccessTime.IsZero() {
+		return false
+	}
+	return clock.Now().Sub(h.LastSuccessTime) <= h.GetMaxDataAge()
+}
+
+// Whether the data being shown is left over from before a failed fetch
+func (h *HttpHelper) IsStale() bool {
+	h.Lock.Lock()
+	defer h.Lock.Unlock()
+	return h.IsStaleLocked()
+}
+
+// Same as IsStale, but expects the caller to hold the lock
+func (h *HttpHelper) IsStaleLocked() bool {
+	return h.HasDataLocked() && !h.LastFetchSuccess
+}
+
+// Waits longer after each failed attempt, with some randomness so retries
+// from several slides don't all line up
+func RetryDelay(attempt int) time.Duration {
+	d := HTTP_RETRY_BASE_DELAY << uint(attempt-1)
+	return time.Duration(float64(d) * (0.5 + rand.Float64()))
 }
 
-func (h *HttpHelper) BuildRequest() (*http.Request, error) {
+func (h *HttpHelper) BuildRequest(ctx context.Context) (*http.Request, error) {
 	if h.Config.RequestUrlCallback != nil {
-		return h.Config.RequestUrlCallback()
+		return h.Config.RequestUrlCallback(ctx)
 	}
-	req, err := http.NewRequest("GET", h.Config.RequestUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", h.Config.RequestUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 	return req, nil
 }
 
-func (h *HttpHelper) Fetch() {
-	req, reqErr := h.BuildRequest()
+// Fetches and parses the data, retrying if the server couldn't be reached.
+// Gives up as soon as the context is cancelled.
+func (h *HttpHelper) Fetch(ctx context.Context) {
+	h.FetchLock.Lock()
+	defer h.FetchLock.Unlock()
+	h.FetchLocked(ctx)
+}
+
+// Same as Fetch, but expects the caller to hold the fetch lock
+func (h *HttpHelper) FetchLocked(ctx context.Context) {
+	for attempt := 1; ; attempt++ {
+		success, retryable := h.FetchOnce(ctx)
+		if success {
+			h.Lock.Lock()
+			h.LastFetchSuccess = true
+			h.LastSuccessTime = clock.Now()
+			h.ConsecutiveFailures = 0
+			h.Lock.Unlock()
+			return
+		}
+		// Being stopped isn't a failure, so leave the status as it was
+		if ctx.Err() != nil {
+			return
+		}
+		if !retryable || attempt >= HTTP_RETRY_ATTEMPTS {
+			h.Lock.Lock()
+			h.LastFetchSuccess = false
+			h.ConsecutiveFailures++
+			h.Lock.Unlock()
+			return
+		}
+
+		delay := RetryDelay(attempt)
+		log.WithFields(log.Fields{
+			"slide":   h.Config.SlideId,
+			"attempt": attempt,
+			"delay":   delay,
+		}).Debug("Retrying fetch.")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Returns whether the fetch succeeded, and if not, whether it's worth trying
+// again. Only connection problems and server errors are retried.
+func (h *HttpHelper) FetchOnce(ctx context.Context) (bool, bool) {
+	req, reqErr := h.BuildRequest(ctx)
 	if reqErr != nil {
 		log.WithFields(log.Fields{
 			"slide": h.Config.SlideId,
 			"req":   req,
 			"error": reqErr,
 		}).Warn("Request error in HttpHelper.")
-		h.LastFetchSuccess = false
-		return
+		return false, false
+	}
+
+	// Only the fetch in progress changes the cache, so a copy stays current
+	h.Lock.Lock()
+	cache := h.Cache
+	hasData := h.HasDataLocked()
+	h.Lock.Unlock()
+
+	// The cache only holds responses that parsed, so it's only worth asking
+	// whether it has changed if it's from this URL and still in use
+	cached := hasData && cache.Url == req.URL.String() && cache.Body != ""
+
+	// No need to ask if the server said the data won't have changed yet
+	if cached && cache.IsFresh() {
+		log.WithFields(log.Fields{
+			"slide":   h.Config.SlideId,
+			"expires": cache.Expires,
+		}).Debug("Cached response is still fresh, skipping fetch.")
+		return true, false
+	}
+	if cached {
+		cache.AddConditionalHeaders(req)
 	}
 
 	res, resErr := h.Client.Do(req)
-	if resErr != nil {
+	if resErr != nil && ctx.Err() != nil {
+		log.WithFields(log.Fields{
+			"slide": h.Config.SlideId,
+		}).Debug("Fetch cancelled.")
+		return false, false
+	} else if resErr != nil {
 		log.WithFields(log.Fields{
 			"slide": h.Config.SlideId,
 			"req":   req,
 			"res":   res,
 			"error": resErr,
 		}).Warn("Response error in HttpHelper.")
-		h.LastFetchSuccess = false
-		return
+		return false, true
+	}
+	defer res.Body.Close()
+
+	// Without a cached response there's nothing for a 304 to refer to, so
+	// drop the validators and try again without them
+	if res.StatusCode == http.StatusNotModified && !cached {
+		log.WithFields(log.Fields{
+			"slide": h.Config.SlideId,
+			"req":   req,
+		}).Warn("Got unexpected 304 response in HttpHelper.")
+		h.SetCache(HttpCacheEntry{})
+		return false, true
+	}
+
+	// Nothing has changed, so the data already parsed is still good
+	if res.StatusCode == http.StatusNotModified {
+		log.WithFields(log.Fields{
+			"slide": h.Config.SlideId,
+			"req":   req,
+		}).Debug("Response not modified.")
+		cache.Refresh(res)
+		h.SetCache(cache)
+		return true, false
 	}
 
 	if res.StatusCode != 200 {
@@ -105,20 +369,33 @@ func (h *HttpHelper) Fetch() {
 			"req":   req,
 			"res":   res,
 		}).Warn("Got non-200 response code in HttpHelper.")
-		h.LastFetchSuccess = false
-		return
+		return false, res.StatusCode >= 500 || res.StatusCode == 429
 	}
 
 	resBuf := new(bytes.Buffer)
-	resBuf.ReadFrom(res.Body)
+	if _, err := resBuf.ReadFrom(res.Body); err != nil {
+		// Most likely cancelled partway through, so don't parse what's there
+		log.WithFields(log.Fields{
+			"slide": h.Config.SlideId,
+			"req":   req,
+			"error": err,
+		}).Warn("Could not read response in HttpHelper.")
+		return false, ctx.Err() == nil
+	}
 	resBytes := resBuf.Bytes()
 
-	h.LastFetchSuccess = h.Config.ParseCallback(resBytes)
+	success := h.Config.ParseCallback(resBytes)
+	if success {
+		h.SetCache(NewHttpCacheEntry(req.URL.String(), res, resBytes))
+	} else {
+		// Don't let the server say a response that didn't parse is unchanged
+		h.SetCache(HttpCacheEntry{})
+	}
 
 	log.WithFields(log.Fields{
 		"slide":        h.Config.SlideId,
 		"req":          req,
-		"fetchSuccess": h.LastFetchSuccess,
+		"fetchSuccess": success,
 	}).Debug("Fetch complete.")
 
 	// Output debug file, maybe
@@ -130,4 +407,14 @@ func (h *HttpHelper) Fetch() {
 		}).Debug("Logged HTTP response data.")
 		ioutil.WriteFile(outFile, resBytes, os.FileMode(0770))
 	}
+	return success, false
+}
+
+func (h *HttpHelper) SetCache(e HttpCacheEntry) {
+	h.Lock.Lock()
+	h.Cache = e
+	h.Lock.Unlock()
+	if e.Url != "" {
+		SaveHttpCacheEntry(h.Config.SlideId, e)
+	}
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpHelperRetriesServerErrors(t *testing.T) {
+	defer func(d time.Duration) { HTTP_RETRY_BASE_DELAY = d }(HTTP_RETRY_BASE_DELAY)
+	HTTP_RETRY_BASE_DELAY = time.Millisecond
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 1 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	h := NewHttpHelper(HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback:   func(b []byte) bool { return string(b) == "ok" },
+	})
+	h.Fetch(context.Background())
+	if !h.LastFetchSuccess || requests != 2 {
+		t.Errorf("Got success %v after %d requests, expected a retry to succeed", h.LastFetchSuccess, requests)
+	}
+}
+
+func TestHttpHelperKeepsDataUntilMaxAge(t *testing.T) {
+	fc := UseFakeClock(t, time.Date(2021, 12, 20, 10, 30, 0, 0, time.UTC))
+
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if fail {
+			// Client errors aren't retried, so this fails right away
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	h := NewHttpHelper(HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback:   func(b []byte) bool { return true },
+		MaxDataAge:      5 * time.Minute,
+	})
+	h.Fetch(context.Background())
+	if !h.HasData() || h.IsStale() {
+		t.Fatal("Expected fresh data after a successful fetch")
+	}
+
+	fail = true
+	fc.Advance(4 * time.Minute)
+	h.Fetch(context.Background())
+	if !h.HasData() || !h.IsStale() || h.ConsecutiveFailures != 1 {
+		t.Errorf("Expected stale data after a failed fetch, got %+v", h.GetFetchStatus())
+	}
+
+	fc.Advance(2 * time.Minute)
+	if h.HasData() {
+		t.Error("Expected data to expire after the max age")
+	}
+}
+
+func TestHttpHelperConditionalRequests(t *testing.T) {
+	defer func(st *StateStore) { stateStore = st }(stateStore)
+	stateStore = NewStateStore(t.TempDir())
+
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	parses := 0
+	config := HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback: func(b []byte) bool {
+			parses++
+			return string(b) == "ok"
+		},
+	}
+	h := NewHttpHelper(config)
+	h.Fetch(context.Background())
+	h.Fetch(context.Background())
+	if !h.LastFetchSuccess || requests != 2 || notModified != 1 || parses != 1 {
+		t.Errorf("Got %d requests, %d not modified, %d parses", requests, notModified, parses)
+	}
+
+	// A new helper should pick up the saved response without a request
+	h = NewHttpHelper(config)
+	if !h.LoadCache(context.Background()) || parses != 2 || requests != 2 {
+		t.Errorf("Expected saved response to be loaded, got %d parses", parses)
+	}
+}
+
+func TestHttpHelperHonorsMaxAge(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		res.Header().Set("Cache-Control", "public, max-age=300")
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	h := NewHttpHelper(HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback:   func(b []byte) bool { return true },
+	})
+	h.Fetch(context.Background())
+	h.Fetch(context.Background())
+	if !h.LastFetchSuccess || requests != 1 {
+		t.Errorf("Got %d requests, expected the second fetch to be skipped", requests)
+	}
+}
+
+func TestHttpHelperStopLoopCancelsFetch(t *testing.T) {
+	received := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// Never answers, so only cancelling ends the request
+		close(received)
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	h := NewHttpHelper(HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback:   func(b []byte) bool { return true },
+	})
+	started := make(chan bool)
+	go func() {
+		h.StartLoop(context.Background())
+		close(started)
+	}()
+	<-received
+	h.StopLoop()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fetch was still running after the loop was stopped")
+	}
+	if st := h.GetFetchStatus(); st.ConsecutiveFailures != 0 {
+		t.Errorf("Expected a cancelled fetch not to count as a failure, got %+v", st)
+	}
+}
+
+func TestHttpHelperIgnoresUnexpectedNotModified(t *testing.T) {
+	defer func(d time.Duration) { HTTP_RETRY_BASE_DELAY = d }(HTTP_RETRY_BASE_DELAY)
+	HTTP_RETRY_BASE_DELAY = time.Millisecond
+	defer func(st *StateStore) { stateStore = st }(stateStore)
+	stateStore = NewStateStore(t.TempDir())
+
+	requests := 0
+	var conditional []bool
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		conditional = append(conditional, req.Header.Get("If-None-Match") != "")
+		// Answers as if it had something cached, the first time
+		if requests == 1 {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	parses := 0
+	h := NewHttpHelper(HttpConfig{
+		SlideId:         "Test",
+		RefreshInterval: time.Minute,
+		RequestUrl:      server.URL,
+		ParseCallback: func(b []byte) bool {
+			parses++
+			return string(b) == "ok"
+		},
+	})
+	h.Fetch(context.Background())
+	if !h.LastFetchSuccess || parses != 1 || requests != 2 {
+		t.Errorf("Got success %v with %d parses after %d requests, expected a refetch after the 304",
+			h.LastFetchSuccess, parses, requests)
+	}
+	for i, c := range conditional {
+		if c {
+			t.Errorf("Request %d was conditional without any data to fall back on", i)
+		}
+	}
+}
@@ -1,8 +1,12 @@
+//go:build !nohardware
+// +build !nohardware
+
 package main
 
 import (
 	"image"
 	"image/draw"
+	"sync"
 
 	rgbmatrix "github.com/mcuadros/go-rpi-rgb-led-matrix"
 	log "github.com/sirupsen/logrus"
@@ -11,10 +15,19 @@ import (
 type LedDisplay struct {
 	Matrix rgbmatrix.Matrix
 	Canvas *rgbmatrix.Canvas
+
+	// The rgbmatrix bindings only read the hardware brightness when the
+	// matrix is created, so runtime changes are made by scaling pixel values.
+	Brightness int
+	LastImage  *image.RGBA
+	// Set once the matrix has been released, after which nothing is drawn
+	Closed bool
+	Lock   sync.Mutex
 }
 
 func NewLedDisplay() *LedDisplay {
 	d := new(LedDisplay)
+	d.Brightness = 100
 	config := &rgbmatrix.DefaultConfig
 	config.HardwareMapping = "adafruit-hat-pwm"
 	config.Rows = 32
@@ -39,6 +52,43 @@ func (d *LedDisplay) Initialize() {
 }
 
 func (d *LedDisplay) Redraw(img *image.RGBA) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	if d.Closed {
+		return
+	}
+	d.LastImage = img
+	d.Render()
+}
+
+func (d *LedDisplay) SetBrightness(percent int) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.Brightness = percent
+	// Apply the change right away instead of waiting for the next frame
+	if d.LastImage != nil && !d.Closed {
+		d.Render()
+	}
+}
+
+// Releases the matrix. Nothing more is drawn afterwards.
+func (d *LedDisplay) Close() {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	if d.Closed {
+		return
+	}
+	d.Closed = true
+	if err := d.Matrix.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("Could not close hardware LED matrix.")
+	}
+}
+
+// Expects the caller to hold the lock
+func (d *LedDisplay) Render() {
+	img := ScaleBrightness(d.LastImage, d.Brightness)
 	draw.Draw(d.Canvas, d.Canvas.Bounds(), img, image.Point{}, draw.Src)
 	d.Canvas.Render()
 }
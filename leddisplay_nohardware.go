@@ -0,0 +1,36 @@
+//go:build nohardware
+// +build nohardware
+
+package main
+
+import (
+	"image"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stands in for the hardware display when building without the rgbmatrix
+// C library, e.g. "go build -tags nohardware" on a laptop
+type LedDisplay struct {
+}
+
+func NewLedDisplay() *LedDisplay {
+	log.Error("Built without hardware support, use -display=terminal instead.")
+	return nil
+}
+
+func (d *LedDisplay) Initialize() {
+
+}
+
+func (d *LedDisplay) Redraw(img *image.RGBA) {
+
+}
+
+func (d *LedDisplay) SetBrightness(percent int) {
+
+}
+
+func (d *LedDisplay) Close() {
+
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source of ambient light readings
+type LightSensor interface {
+	// Returns the current light level in lux
+	ReadLux() (float64, error)
+}
+
+// Reads a number from a file, such as an IIO illuminance attribute in sysfs
+// (e.g. /sys/bus/iio/devices/iio:device0/in_illuminance_raw)
+type FileLightSensor struct {
+	Path string
+	// Multiplier converting the raw reading into lux
+	Scale float64
+}
+
+func NewFileLightSensor(path string, scale float64) *FileLightSensor {
+	s := new(FileLightSensor)
+	s.Path = path
+	s.Scale = scale
+	return s
+}
+
+func (s *FileLightSensor) ReadLux() (float64, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * s.Scale, nil
+}
+
+// Readings older than this are ignored, in case whatever pushes them stops
+const PUSHED_LIGHT_MAX_AGE = 5 * time.Minute
+
+// Holds the latest reading sent to the controller by some external process
+type PushedLightSensor struct {
+	Lux     float64
+	Updated time.Time
+	Lock    sync.Mutex
+}
+
+func NewPushedLightSensor() *PushedLightSensor {
+	s := new(PushedLightSensor)
+	return s
+}
+
+func (s *PushedLightSensor) Push(lux float64) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	s.Lux = lux
+	s.Updated = clock.Now()
+}
+
+func (s *PushedLightSensor) ReadLux() (float64, error) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Updated.IsZero() {
+		return 0, fmt.Errorf("no reading has been pushed")
+	}
+	if clock.Now().Sub(s.Updated) > PUSHED_LIGHT_MAX_AGE {
+		return 0, fmt.Errorf("last reading is from %s", s.Updated.Format(time.RFC3339))
+	}
+	return s.Lux, nil
+}
+
+// Maps ambient light readings to a display brightness
+type AmbientLight struct {
+	Sensor LightSensor
+	// At or below DarkLux the display uses MinBrightness, and at or above
+	// BrightLux it uses the configured maximum brightness
+	DarkLux       float64
+	BrightLux     float64
+	MinBrightness int
+	// Smaller changes in brightness than this are ignored to avoid flicker
+	Hysteresis int
+}
+
+// Weight given to each new reading in the running average
+const AMBIENT_SMOOTHING = 0.3
+
+// Blends a new reading into the running average. A negative average means
+// there were no previous readings.
+func SmoothLux(average, reading float64) float64 {
+	if average < 0 {
+		return reading
+	}
+	return average + AMBIENT_SMOOTHING*(reading-average)
+}
+
+// Interpolates brightness on a log scale, which is closer to how the eye
+// perceives differences in light level
+func (a *AmbientLight) BrightnessFor(lux float64, maxBrightness int) int {
+	if lux <= a.DarkLux {
+		return a.MinBrightness
+	}
+	if lux >= a.BrightLux {
+		return maxBrightness
+	}
+	lo := math.Log1p(a.DarkLux)
+	hi := math.Log1p(a.BrightLux)
+	frac := (math.Log1p(lux) - lo) / (hi - lo)
+	return a.MinBrightness + int(math.Round(frac*float64(maxBrightness-a.MinBrightness)))
+}
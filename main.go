@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -9,12 +13,47 @@ import (
 
 type Config struct {
 	AdvanceInterval time.Duration
-	Slides          []Slide
+	Slides          []*SlideEntry
+	Brightness      int
+	NightMode       *NightMode
+	AmbientLight    *AmbientLight
+	Network         Network
+	Transition      Transition
+}
+
+// Resolved network settings, with defaults filled in
+type Network struct {
+	ProbeUrls []string
+	// Zero means wait forever
+	MaxWait    time.Duration
+	NtpServers []string
 }
 
 // Flags that are generally environment-dependent
+var configFlag = flag.String("config", "config.json",
+	"Path to the JSON file defining the slideshow.")
+var displayFlag = flag.String("display", "led",
+	"Where to draw the slideshow: led, terminal, or browser (only the page "+
+		"served by the controller). Log output goes to stderr, so redirect it "+
+		"when using the terminal.")
 var generateImagesFlag = flag.Bool("generate_images", false,
 	"If true, generates slide images instead of running as slideshow.")
+var recordFlag = flag.Bool("record", false,
+	"If true, records each slide to an animated GIF instead of running as slideshow.")
+var recordDurationFlag = flag.Duration("record_duration", 10*time.Second,
+	"How long to record each slide for.")
+var previewBrightnessFlag = flag.Int("preview_brightness", 100,
+	"Brightness percentage to simulate when generating or recording slide images.")
+var renderTimeFlag = flag.String("render_time", "",
+	"If set, slides are drawn as if starting at this time, e.g. 2026-12-31T23:59:50.")
+var httpRecordFlag = flag.String("http_record", "",
+	"If set, saves every HTTP response to this directory for replaying later.")
+var httpReplayFlag = flag.String("http_replay", "",
+	"If set, answers HTTP requests from responses saved with -http_record "+
+		"instead of using the network.")
+var stateDirFlag = flag.String("state_dir", "state",
+	"Directory to keep the last data for each slide in, so it can be shown "+
+		"right after a restart. Set to empty to disable.")
 var debugLogFlag = flag.Bool("debug_log", false,
 	"If true, prints out debug-level log statements.")
 var debugHttp = flag.Bool("debug_http", false,
@@ -40,44 +79,157 @@ func main() {
 		FullTimestamp: true,
 	})
 
-	// Set up the glyph and icon mappings
+	// Draw slides as of some other time, e.g. to preview a countdown
+	if *renderTimeFlag != "" {
+		t, err := ParseClockTime(*renderTimeFlag)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"time":  *renderTimeFlag,
+				"error": err,
+			}).Fatal("Could not parse render time.")
+		}
+		clock = NewOffsetClock(t)
+	}
+
+	// Slides set up their HTTP clients as they're created, so this goes first
+	if *httpRecordFlag != "" && *httpReplayFlag != "" {
+		log.Fatal("Only one of -http_record and -http_replay can be used.")
+	} else if *httpRecordFlag != "" {
+		httpTransport = NewCassetteTransport(*httpRecordFlag, CASSETTE_RECORD)
+	} else if *httpReplayFlag != "" {
+		httpTransport = NewCassetteTransport(*httpReplayFlag, CASSETTE_REPLAY)
+	}
+
+	if *stateDirFlag != "" {
+		stateStore = NewStateStore(*stateDirFlag)
+	}
+
+	// Set up the glyph, icon, and slide type mappings
 	InitGlyphs()
 	InitIcons()
+	InitSlideFactories()
+
+	// Fail early if the slideshow isn't defined correctly
+	config, err := LoadConfig(*configFlag)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file":  *configFlag,
+			"error": err,
+		}).Fatal("Could not load config.")
+	}
+
+	// Everything started from here stops once this is cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if *generateImagesFlag {
-		GenerateImages()
+		GenerateImages(ctx, config)
+	} else if *recordFlag {
+		RecordSlides(ctx, config)
 	} else {
-		RunAsSlideshow()
+		RunAsSlideshow(ctx, stop, config)
 	}
 }
 
-func RunAsSlideshow() {
-	// Grab the global config object to pass elsewhere
-	config := GetConfig()
-
-	// Set up the display on hardware
-	d := NewLedDisplay()
+func RunAsSlideshow(ctx context.Context, stop context.CancelFunc, config *Config) {
+	// Draw on the chosen output, and keep a copy of each frame for remote preview
+	p := NewPreviewDisplay()
+	displays := []Display{p}
+	switch *displayFlag {
+	case "led":
+		if led := NewLedDisplay(); led != nil {
+			displays = append(displays, led)
+		}
+	case "terminal":
+		displays = append(displays, NewTerminalDisplay())
+	case "browser":
+		log.Info("Drawing only to the browser, open the controller address to view.")
+	default:
+		log.WithFields(log.Fields{
+			"display": *displayFlag,
+		}).Fatal("Unknown display type.")
+	}
+	d := NewMultiDisplay(displays...)
 	d.Initialize()
 
+	// Dim or blank the display on a schedule or to match the room
+	b := NewBrightnessController(d, config)
+	b.Start(ctx)
+
 	// Set up the slideshow (controls drawing and advancing)
-	s := NewSlideshow(d, config)
+	s := NewSlideshow(ctx, d, config)
 	s.Start()
 
 	// Start the HTTP show controller, which keeps the program running
-	c := NewController(s)
-	c.RunUntilShutdown()
+	c := NewController(s, stop)
+	c.Brightness = b
+	c.Preview = p
+
+	// Allow the config file to be re-read without restarting
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			log.Info("Received SIGHUP, reloading config.")
+			c.Reload()
+		}
+	}()
+
+	c.RunUntilShutdown(ctx)
+	// A second signal kills the program, in case stopping gets stuck
+	stop()
+
+	// Stop slides drawing and fetching, then leave the panel dark. The show
+	// might already be stopped, so blank the display either way.
+	log.Info("Shutting down.")
+	s.Stop()
+	s.Compositor.Clear()
+	d.Close()
 }
 
-func GenerateImages() {
-	config := GetConfig()
-
+func GenerateImages(ctx context.Context, config *Config) {
 	d := NewSaveToFileDisplay()
+	d.SetBrightness(*previewBrightnessFlag)
+
+	// For each slide, initialize then draw once, along with the transition
+	// into it from the slide before
+	c := NewCompositor(d)
+	for _, e := range config.Slides {
+		d.SetSlideId(e.Slide)
+		e.Slide.Initialize(ctx)
+		from := c.GetFrame()
+		c.Show(e.Slide)
+		// Nothing more is drawn, so stop the slide fetching in the background
+		e.Slide.Terminate()
+		t := e.GetTransition(config.Transition)
+		if !t.IsCut() {
+			d.SaveTransition(t.Frames(from, c.GetFrame(), TRANSITION_PREVIEW_FRAMES))
+		}
+	}
+}
 
-	// For each slide, initialize then draw once
-	for _, s := range config.Slides {
-		d.SetSlideId(s)
-		s.Initialize()
-		s.StartDraw(d)
-		s.StopDraw()
+func RecordSlides(ctx context.Context, config *Config) {
+	d := NewRecordingDisplay()
+	d.SetBrightness(*previewBrightnessFlag)
+
+	// For each slide, initialize then let it draw for a while. Each recording
+	// starts with the transition from the slide before.
+	c := NewCompositor(d)
+	c.Start(ctx)
+	for _, e := range config.Slides {
+		e.Slide.Initialize(ctx)
+		d.StartRecording(e.Slide)
+		c.ShowWithTransition(e.Slide, e.GetTransition(config.Transition))
+		select {
+		case <-time.After(*recordDurationFlag):
+		case <-ctx.Done():
+		}
+		c.Show(nil)
+		e.Slide.Terminate()
+		d.StopRecording()
+		// Keep what was recorded so far if interrupted
+		if ctx.Err() != nil {
+			return
+		}
 	}
 }
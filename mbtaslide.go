@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -9,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -18,8 +20,13 @@ type MbtaSlide struct {
 	StationName string
 	Predictions []MbtaPrediction
 
-	HttpHelper   *HttpHelper
-	RedrawTicker *time.Ticker
+	HttpHelper *HttpHelper
+	// Scrolls destinations too long to fit
+	Marquee *Marquee
+	// Whether any destination was scrolling in the last frame
+	Scrolling bool
+	// Guards Predictions, which is replaced by each fetch, and Scrolling
+	Lock sync.Mutex
 }
 
 // Station names - used in constructor
@@ -56,6 +63,7 @@ func NewMbtaSlide(stationId string) *MbtaSlide {
 		name = "?????"
 	}
 	sl.StationName = name
+	sl.Marquee = NewMarquee()
 
 	sl.HttpHelper = NewHttpHelper(HttpConfig{
 		SlideId:         "MBTASlide-" + stationId,
@@ -68,26 +76,35 @@ func NewMbtaSlide(stationId string) *MbtaSlide {
 	return sl
 }
 
-func (sl *MbtaSlide) Initialize() {
-	sl.HttpHelper.StartLoop()
+func (sl *MbtaSlide) Initialize(ctx context.Context) {
+	sl.HttpHelper.StartLoop(ctx)
 }
 
 func (sl *MbtaSlide) Terminate() {
 	sl.HttpHelper.StopLoop()
 }
 
-func (sl *MbtaSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEverySecond(d, sl.Draw)
+func (sl *MbtaSlide) GetFrameInterval() time.Duration {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	if sl.Scrolling {
+		return sl.Marquee.GetFrameInterval()
+	}
+	return 1 * time.Second
 }
 
-func (sl *MbtaSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *MbtaSlide) RestartAnimation() {
+	sl.Marquee.Restart()
 }
 
 func (sl *MbtaSlide) IsEnabled() bool {
 	return true // Always enabled
 }
 
+func (sl *MbtaSlide) GetFetchStatus() FetchStatus {
+	return sl.HttpHelper.GetFetchStatus()
+}
+
 func (sl *MbtaSlide) Parse(respBytes []byte) bool {
 	// Parse response to JSON
 	var resp MbtaApiResponse
@@ -106,11 +123,22 @@ func (sl *MbtaSlide) Parse(respBytes []byte) bool {
 	predictionsByRoute := sl.BuildRouteToPredictionsMap(resp.Data, routeByTripId)
 
 	// Flatten the predictions into what will be displayed
-	sl.Predictions = sl.FlattenPredictions(routeByTripId, predictionsByRoute)
+	predictions := sl.FlattenPredictions(routeByTripId, predictionsByRoute)
 
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.Predictions = predictions
 	return true
 }
 
+// Parsing replaces the whole list rather than changing it, so it's safe to
+// use the returned one without holding the lock
+func (sl *MbtaSlide) GetPredictions() []MbtaPrediction {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	return sl.Predictions
+}
+
 func (sl *MbtaSlide) BuildTripIdToRouteMap(resources []MbtaApiResource) map[string]MbtaRoute {
 	// Iterate through all provided "route" resources, building a mapping
 	// of route ID (string) to structure route object (with name and color).
@@ -246,7 +274,7 @@ func (sl *MbtaSlide) FilterTimesInPast(all []MbtaPrediction) (ret []MbtaPredicti
 	for _, p := range all {
 		var times []time.Time
 		for _, t := range p.Time {
-			if time.Until(t) >= 0 {
+			if t.Sub(clock.Now()) >= 0 {
 				times = append(times, t)
 			}
 		}
@@ -259,7 +287,15 @@ func (sl *MbtaSlide) FilterTimesInPast(all []MbtaPrediction) (ret []MbtaPredicti
 }
 
 func (sl *MbtaSlide) Draw(img *image.RGBA) {
-	if !sl.HttpHelper.LastFetchSuccess {
+	// Only redraw often enough to scroll while something needs to
+	scrolling := false
+	defer func() {
+		sl.Lock.Lock()
+		sl.Scrolling = scrolling
+		sl.Lock.Unlock()
+	}()
+
+	if !sl.HttpHelper.HasData() {
 		DrawError(img, "MBTA Trains", "No data.")
 		return
 	}
@@ -269,7 +305,7 @@ func (sl *MbtaSlide) Draw(img *image.RGBA) {
 	busColor := color.RGBA{255, 255, 0, 255}    // yellow
 	timeColor := color.RGBA{0, 255, 255, 255}   // aqua
 
-	filteredPredictions := sl.FilterTimesInPast(sl.Predictions)
+	filteredPredictions := sl.FilterTimesInPast(sl.GetPredictions())
 
 	if len(filteredPredictions) == 0 {
 		DrawError(img, "MBTA Trains", "No predictions.")
@@ -295,7 +331,7 @@ func (sl *MbtaSlide) Draw(img *image.RGBA) {
 		// Loop through first three predictions, or all, whichever is less
 		for j := 0; j < min(len(p.Time), 3); j++ {
 			t := p.Time[j]
-			est := time.Until(t)
+			est := t.Sub(clock.Now())
 			estMin := int(math.Floor(est.Minutes()))
 			estStrs = append(estStrs, strconv.Itoa(estMin))
 		}
@@ -313,18 +349,20 @@ func (sl *MbtaSlide) Draw(img *image.RGBA) {
 		// Size of box is different based on how many time digits to display
 		destWidth := 116 - GetDisplayWidth(estStr)
 
-		// Destination
+		// Destination, scrolling if it's too long for the space
 		dest := strings.ToUpper(p.Route.Destination)
-		// If the name of the place exceeds the available space, try to truncate the name.
-		if GetDisplayWidth(dest) > destWidth {
-			dest = strings.Split(dest, " ")[0]
+		if sl.Marquee.Draw(img, dest, textColor, ALIGN_LEFT, 12, y, destWidth) {
+			scrolling = true
 		}
-		WriteStringBoxed(img, dest, textColor, ALIGN_LEFT, 12, y, destWidth)
 
 		// Time estimate
 		imgWidth := img.Bounds().Dx()
 		WriteString(img, estStr, timeColor, ALIGN_RIGHT, imgWidth-1, y)
 	}
+
+	if sl.HttpHelper.IsStale() {
+		DrawStaleMarker(img)
+	}
 }
 
 // Data structures used by the MBTA API - used for parsing responses
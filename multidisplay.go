@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Forwards each frame to several displays. Every display gets its own
+// goroutine, so a slow or failing one can't hold up the slides drawing.
+type MultiDisplay struct {
+	Sinks []*DisplaySink
+	// How long Close waits in total for displays to finish drawing
+	CloseTimeout time.Duration
+}
+
+const MULTI_DISPLAY_CLOSE_TIMEOUT = 5 * time.Second
+
+// One display fed by a MultiDisplay
+type DisplaySink struct {
+	Display Display
+	Name    string
+
+	// Holds at most one pending frame. If the display hasn't picked it up by
+	// the time the next frame arrives, the older one is dropped.
+	Frames  chan *image.RGBA
+	Dropped int
+	// Set once closed, after which frames are ignored
+	Closed bool
+	// Closed once the display has drawn its last frame
+	Done chan bool
+	Lock sync.Mutex
+}
+
+func NewMultiDisplay(displays ...Display) *MultiDisplay {
+	d := new(MultiDisplay)
+	d.CloseTimeout = MULTI_DISPLAY_CLOSE_TIMEOUT
+	for _, inner := range displays {
+		sink := new(DisplaySink)
+		sink.Display = inner
+		sink.Name = fmt.Sprintf("%T", inner)
+		sink.Frames = make(chan *image.RGBA, 1)
+		sink.Done = make(chan bool)
+		d.Sinks = append(d.Sinks, sink)
+		go sink.Run()
+	}
+	return d
+}
+
+func (d *MultiDisplay) Initialize() {
+	for _, sink := range d.Sinks {
+		sink.Call("Initialize", sink.Display.Initialize)
+	}
+}
+
+// Hands the frame to each display without waiting for them to draw it.
+// Displays must not modify the image.
+func (d *MultiDisplay) Redraw(img *image.RGBA) {
+	for _, sink := range d.Sinks {
+		sink.Offer(img)
+	}
+}
+
+func (d *MultiDisplay) SetBrightness(percent int) {
+	for _, sink := range d.Sinks {
+		if dd, ok := sink.Display.(DimmableDisplay); ok && !sink.IsClosed() {
+			sink.Call("SetBrightness", func() { dd.SetBrightness(percent) })
+		}
+	}
+}
+
+// Lets each display finish drawing its last frame, then closes the ones
+// that need it. Displays still drawing after the timeout are left alone, so
+// one that's hung can't block shutdown.
+func (d *MultiDisplay) Close() {
+	for _, sink := range d.Sinks {
+		sink.Lock.Lock()
+		if !sink.Closed {
+			sink.Closed = true
+			close(sink.Frames)
+		}
+		sink.Lock.Unlock()
+	}
+	deadline := clock.NewTicker(d.CloseTimeout)
+	defer deadline.Stop()
+	expired := false
+	for _, sink := range d.Sinks {
+		if !expired {
+			select {
+			case <-sink.Done:
+			case <-deadline.C():
+				expired = true
+			}
+		}
+		if expired {
+			select {
+			case <-sink.Done:
+			default:
+				log.WithFields(log.Fields{
+					"display": sink.Name,
+					"timeout": d.CloseTimeout,
+				}).Warn("Display is still drawing, closing without it.")
+				continue
+			}
+		}
+		if cd, ok := sink.Display.(ClosableDisplay); ok {
+			sink.Call("Close", cd.Close)
+		}
+	}
+}
+
+// Queues a frame, replacing any frame still waiting to be drawn
+func (sink *DisplaySink) Offer(img *image.RGBA) {
+	sink.Lock.Lock()
+	defer sink.Lock.Unlock()
+	if sink.Closed {
+		return
+	}
+	select {
+	case <-sink.Frames:
+		sink.Dropped++
+		log.WithFields(log.Fields{
+			"display": sink.Name,
+			"dropped": sink.Dropped,
+		}).Debug("Display is behind, dropping frame.")
+	default:
+	}
+	sink.Frames <- img
+}
+
+func (sink *DisplaySink) IsClosed() bool {
+	sink.Lock.Lock()
+	defer sink.Lock.Unlock()
+	return sink.Closed
+}
+
+func (sink *DisplaySink) Run() {
+	defer close(sink.Done)
+	for img := range sink.Frames {
+		sink.Call("Redraw", func() { sink.Display.Redraw(img) })
+	}
+}
+
+// Runs fn, logging rather than crashing if the display panics
+func (sink *DisplaySink) Call(method string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithFields(log.Fields{
+				"display": sink.Name,
+				"method":  method,
+				"error":   r,
+			}).Warn("Display failed.")
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"image"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the frames it's given. Redraw blocks while the display is held,
+// and panics on frames in PanicOn.
+type RecordingSink struct {
+	Started chan *image.RGBA
+	Hold    chan bool
+	PanicOn *image.RGBA
+	Drawn   []*image.RGBA
+	Closed  bool
+	Lock    sync.Mutex
+}
+
+func NewRecordingSink() *RecordingSink {
+	r := new(RecordingSink)
+	r.Started = make(chan *image.RGBA, 100)
+	return r
+}
+
+func (r *RecordingSink) Initialize() {}
+
+func (r *RecordingSink) Redraw(img *image.RGBA) {
+	r.Started <- img
+	if r.Hold != nil {
+		<-r.Hold
+	}
+	if img == r.PanicOn {
+		panic("display failed")
+	}
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	r.Drawn = append(r.Drawn, img)
+}
+
+func (r *RecordingSink) Close() {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	r.Closed = true
+}
+
+func (r *RecordingSink) GetDrawn() []*image.RGBA {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	return r.Drawn
+}
+
+func (r *RecordingSink) IsClosed() bool {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	return r.Closed
+}
+
+func BuildFrames(n int) []*image.RGBA {
+	var frames []*image.RGBA
+	for i := 0; i < n; i++ {
+		frames = append(frames, NewBlankImage())
+	}
+	return frames
+}
+
+func TestMultiDisplayDropsFramesWhenBehind(t *testing.T) {
+	slow := NewRecordingSink()
+	slow.Hold = make(chan bool)
+	fast := NewRecordingSink()
+	d := NewMultiDisplay(slow, fast)
+	frames := BuildFrames(5)
+
+	// The slow display is stuck on the first frame while the rest arrive
+	d.Redraw(frames[0])
+	<-slow.Started
+	<-fast.Started
+	for _, f := range frames[1:] {
+		d.Redraw(f)
+		<-fast.Started
+	}
+	close(slow.Hold)
+	d.Close()
+
+	if got := fast.GetDrawn(); len(got) != len(frames) {
+		t.Errorf("Expected the fast display to draw all %d frames, got %d", len(frames), len(got))
+	}
+	got := slow.GetDrawn()
+	if len(got) != 2 || got[0] != frames[0] || got[1] != frames[4] {
+		t.Errorf("Expected the slow display to draw the first and latest frames, got %d frames", len(got))
+	}
+	if d.Sinks[0].Dropped != 3 || d.Sinks[1].Dropped != 0 {
+		t.Errorf("Got %d and %d dropped frames, expected 3 and 0", d.Sinks[0].Dropped, d.Sinks[1].Dropped)
+	}
+	if !slow.IsClosed() || !fast.IsClosed() {
+		t.Error("Expected both displays to be closed")
+	}
+}
+
+func TestMultiDisplayRecoversFromPanic(t *testing.T) {
+	frames := BuildFrames(2)
+	failing := NewRecordingSink()
+	failing.PanicOn = frames[0]
+	other := NewRecordingSink()
+	d := NewMultiDisplay(failing, other)
+
+	for _, f := range frames {
+		d.Redraw(f)
+		<-failing.Started
+		<-other.Started
+	}
+	d.Close()
+
+	got := failing.GetDrawn()
+	if len(got) != 1 || got[0] != frames[1] {
+		t.Errorf("Expected the display to draw the frame after it panicked, got %d frames", len(got))
+	}
+	if len(other.GetDrawn()) != 2 {
+		t.Errorf("Expected the other display to draw both frames, got %d", len(other.GetDrawn()))
+	}
+	if !failing.IsClosed() {
+		t.Error("Expected the display that panicked to still be closed")
+	}
+}
+
+func TestMultiDisplayCloseTimesOut(t *testing.T) {
+	hung := NewRecordingSink()
+	hung.Hold = make(chan bool)
+	defer close(hung.Hold)
+	other := NewRecordingSink()
+	d := NewMultiDisplay(hung, other)
+	d.CloseTimeout = 10 * time.Millisecond
+
+	d.Redraw(NewBlankImage())
+	<-hung.Started
+
+	closed := make(chan bool)
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to give up on the hung display")
+	}
+	if hung.IsClosed() {
+		t.Error("Expected the hung display not to be closed while it's drawing")
+	}
+	if !other.IsClosed() {
+		t.Error("Expected the other display to be closed")
+	}
+}
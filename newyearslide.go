@@ -1,18 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
+	"sync"
 	"time"
 )
 
 type NewYearSlide struct {
 	Midnight  time.Time
 	Fireworks []*Firework
-
-	RedrawTicker *time.Ticker
+	// Guards the fields above, since drawing moves the fireworks along
+	Lock sync.Mutex
 }
 
 const FPS = 4.0
@@ -22,14 +24,16 @@ func NewNewYearSlide() *NewYearSlide {
 	return sl
 }
 
-func (sl *NewYearSlide) Initialize() {
-	t := time.Now()
+func (sl *NewYearSlide) Initialize(ctx context.Context) {
+	t := clock.Now()
 	year := t.Year() + 1
 	// If it's January, the new year just passed so we want to count to the
 	// current year instead (and show zeros).
 	if t.Month() == time.January {
 		year = t.Year()
 	}
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
 	sl.Midnight = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
 }
 
@@ -37,8 +41,10 @@ func (sl *NewYearSlide) Terminate() {
 
 }
 
-func (sl *NewYearSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEveryInterval((1000/FPS)*time.Millisecond, d, sl.Draw)
+// Sets up the fireworks before anything is drawn
+func (sl *NewYearSlide) RestartAnimation() {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
 	sl.Fireworks = []*Firework{
 		sl.createFirework(10, 8, 255, 0, 0),
 		sl.createFirework(27, 12, 255, 255, 0),
@@ -47,21 +53,26 @@ func (sl *NewYearSlide) StartDraw(d Display) {
 	}
 }
 
-func (sl *NewYearSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *NewYearSlide) GetFrameInterval() time.Duration {
+	return (1000 / FPS) * time.Millisecond
 }
 
 func (sl *NewYearSlide) IsEnabled() bool {
-	diff := time.Until(sl.Midnight)
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	diff := sl.Midnight.Sub(clock.Now())
 	return diff > (-1 * time.Hour)
 }
 
 func (sl *NewYearSlide) Draw(img *image.RGBA) {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+
 	c0 := color.RGBA{0, 255, 255, 255}
 	c1 := color.RGBA{255, 255, 255, 255}
 	c2 := color.RGBA{0, 255, 0, 255}
 
-	diff := time.Until(sl.Midnight)
+	diff := sl.Midnight.Sub(clock.Now())
 	if diff < 0 {
 		diff = 0
 	}
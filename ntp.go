@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Seconds from the NTP epoch (1900) to the Unix epoch (1970)
+const NTP_EPOCH_OFFSET = 2208988800
+
+const NTP_TIMEOUT = 5 * time.Second
+
+// Smaller differences aren't worth stepping the clock for, same as ntpdate
+const NTP_STEP_THRESHOLD = 500 * time.Millisecond
+
+// Result of the last attempt to set the clock, as reported by the controller
+type TimeSyncStatus struct {
+	Server string    `json:"server,omitempty"`
+	Time   time.Time `json:"time"`
+	// How far the system clock was behind the server, negative if ahead
+	OffsetSeconds float64 `json:"offset_seconds"`
+	// Whether the system clock was changed to match
+	Adjusted bool   `json:"adjusted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Sets the system clock from the first server that answers, in case the Pi
+// lost power for a while
+func SyncTime(servers []string) *TimeSyncStatus {
+	st := &TimeSyncStatus{Time: time.Now()}
+	for _, server := range servers {
+		offset, err := QueryNtp(server)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"server": server,
+				"error":  err,
+			}).Warn("Failed NTP query.")
+			st.Error = err.Error()
+			continue
+		}
+
+		st = &TimeSyncStatus{
+			Server:        server,
+			Time:          time.Now(),
+			OffsetSeconds: offset.Seconds(),
+		}
+		if offset > NTP_STEP_THRESHOLD || offset < -NTP_STEP_THRESHOLD {
+			if err := SetSystemTime(time.Now().Add(offset)); err != nil {
+				log.WithFields(log.Fields{
+					"offset": offset,
+					"error":  err,
+				}).Warn("Could not set system time.")
+				st.Error = err.Error()
+			} else {
+				st.Adjusted = true
+			}
+		}
+		log.WithFields(log.Fields{
+			"server":   server,
+			"offset":   offset,
+			"adjusted": st.Adjusted,
+		}).Info("Synchronized time.")
+		return st
+	}
+	return st
+}
+
+// Asks an SNTP server (RFC 4330) how far off the local clock is
+func QueryNtp(server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, NTP_TIMEOUT)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(NTP_TIMEOUT))
+
+	// Leap indicator 0, version 4, client mode. The transmit time comes back
+	// as the origin time, which ties the response to this request.
+	req := make([]byte, 48)
+	req[0] = 0x23
+	sent := time.Now()
+	PutNtpTime(req[40:], sent)
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	res := make([]byte, 48)
+	n, err := conn.Read(res)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 {
+		return 0, fmt.Errorf("short NTP response (%d bytes)", n)
+	}
+	if res[0]&0x7 != 4 {
+		return 0, fmt.Errorf("NTP response not in server mode")
+	}
+	// Stratum 0 is a "kiss of death", telling clients to go away
+	if res[1] == 0 {
+		return 0, fmt.Errorf("NTP server refused request (%q)", res[12:16])
+	}
+	if string(res[24:32]) != string(req[40:48]) {
+		return 0, fmt.Errorf("NTP response doesn't match request")
+	}
+
+	serverReceived := GetNtpTime(res[32:])
+	serverSent := GetNtpTime(res[40:])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// Reads a 64-bit NTP timestamp: seconds since 1900, then a binary fraction
+func GetNtpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4]))
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	// Seconds wrap in 2036, after which the top bit is clear again
+	if secs&0x80000000 == 0 {
+		secs += 1 << 32
+	}
+	return time.Unix(secs-NTP_EPOCH_OFFSET, (frac*1e9)>>32)
+}
+
+func PutNtpTime(b []byte, t time.Time) {
+	secs := uint32(t.Unix() + NTP_EPOCH_OFFSET)
+	frac := uint32((int64(t.Nanosecond()) << 32) / 1e9)
+	binary.BigEndian.PutUint32(b[0:4], secs)
+	binary.BigEndian.PutUint32(b[4:8], frac)
+}
+
+// Needs root, which the LED matrix needs anyway
+func SetSystemTime(t time.Time) error {
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	return syscall.Settimeofday(&tv)
+}
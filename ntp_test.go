@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQueryNtp(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Pretend to be a server whose clock is an hour ahead
+	ahead := time.Hour
+	go func() {
+		req := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(req)
+		if err != nil {
+			return
+		}
+		res := make([]byte, 48)
+		res[0] = 0x24 // Version 4, server mode
+		res[1] = 1
+		copy(res[24:32], req[40:48])
+		now := time.Now().Add(ahead)
+		PutNtpTime(res[32:], now)
+		PutNtpTime(res[40:], now)
+		conn.WriteTo(res, addr)
+	}()
+
+	offset, err := QueryNtp(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := offset - ahead; d > time.Second || d < -time.Second {
+		t.Errorf("Got offset %v, expected about %v", offset, ahead)
+	}
+}
+
+func TestNtpTimeRoundTrip(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2021, 12, 20, 10, 30, 0, 250000000, time.UTC),
+		// After the 32-bit seconds wrap around
+		time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		b := make([]byte, 8)
+		PutNtpTime(b, want)
+		got := GetNtpTime(b)
+		if d := got.Sub(want); d > time.Microsecond || d < -time.Microsecond {
+			t.Errorf("Got %v, expected %v", got, want)
+		}
+	}
+}
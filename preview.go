@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image"
+	"sync"
+)
+
+// Keeps a copy of the last frame, so it can be viewed remotely through the
+// controller
+type PreviewDisplay struct {
+	LastFrame   *image.RGBA
+	Brightness  int
+	Subscribers map[chan *image.RGBA]bool
+	Lock        sync.Mutex
+}
+
+func NewPreviewDisplay() *PreviewDisplay {
+	d := new(PreviewDisplay)
+	d.Brightness = 100
+	d.Subscribers = make(map[chan *image.RGBA]bool)
+	return d
+}
+
+func (d *PreviewDisplay) Initialize() {
+
+}
+
+func (d *PreviewDisplay) Redraw(img *image.RGBA) {
+	// Slides may keep drawing on the same image, so hold onto a copy
+	frame := image.NewRGBA(img.Bounds())
+	copy(frame.Pix, img.Pix)
+
+	d.Lock.Lock()
+	d.LastFrame = frame
+	d.NotifyLocked()
+	d.Lock.Unlock()
+}
+
+func (d *PreviewDisplay) SetBrightness(percent int) {
+	d.Lock.Lock()
+	d.Brightness = percent
+	d.NotifyLocked()
+	d.Lock.Unlock()
+}
+
+// Returns the last frame as it appears on the display, or nil if nothing has
+// been drawn yet
+func (d *PreviewDisplay) GetFrame() *image.RGBA {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	return d.GetFrameLocked()
+}
+
+// Expects the caller to hold the lock
+func (d *PreviewDisplay) GetFrameLocked() *image.RGBA {
+	if d.LastFrame == nil {
+		return nil
+	}
+	return ScaleBrightness(d.LastFrame, d.Brightness)
+}
+
+// Returns a channel that receives each new frame. Slow readers miss frames
+// rather than holding up drawing.
+func (d *PreviewDisplay) Subscribe() chan *image.RGBA {
+	ch := make(chan *image.RGBA, 1)
+	d.Lock.Lock()
+	d.Subscribers[ch] = true
+	d.Lock.Unlock()
+	return ch
+}
+
+func (d *PreviewDisplay) Unsubscribe(ch chan *image.RGBA) {
+	d.Lock.Lock()
+	delete(d.Subscribers, ch)
+	d.Lock.Unlock()
+}
+
+// Expects the caller to hold the lock
+func (d *PreviewDisplay) NotifyLocked() {
+	frame := d.GetFrameLocked()
+	if frame == nil {
+		return
+	}
+	for ch := range d.Subscribers {
+		// Replace any frame the subscriber hasn't picked up yet
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestControllerSendsFrame(t *testing.T) {
+	ctrl := NewTestController()
+	ctrl.Preview = NewPreviewDisplay()
+
+	res := httptest.NewRecorder()
+	ctrl.ServeHTTP(res, httptest.NewRequest("GET", "/frame.png", nil))
+	if res.Code != 503 {
+		t.Errorf("Got status code %d before anything was drawn, expected 503", res.Code)
+	}
+
+	img := NewBlankImage()
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	ctrl.Preview.Redraw(img)
+
+	res = httptest.NewRecorder()
+	ctrl.ServeHTTP(res, httptest.NewRequest("GET", "/frame.png", nil))
+	if res.Code != 200 {
+		t.Fatalf("Got status code %d, expected 200", res.Code)
+	}
+	if ct := res.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Got content type %q, expected image/png", ct)
+	}
+	frame, err := png.Decode(bytes.NewReader(res.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := frame.Bounds()
+	if b.Dx() != SCREEN_WIDTH*PREVIEW_SCALE || b.Dy() != SCREEN_HEIGHT*PREVIEW_SCALE {
+		t.Errorf("Got a %dx%d frame, expected %dx%d", b.Dx(), b.Dy(),
+			SCREEN_WIDTH*PREVIEW_SCALE, SCREEN_HEIGHT*PREVIEW_SCALE)
+	}
+	// The middle of the first LED is lit
+	r, g, _, _ := frame.At(PREVIEW_SCALE/2, PREVIEW_SCALE/2).RGBA()
+	if r>>8 < 128 || g>>8 > 64 {
+		t.Errorf("Expected the first LED to be red, got %v", frame.At(PREVIEW_SCALE/2, PREVIEW_SCALE/2))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Size of each LED in recorded animations. Smaller than still renderings to
+// keep the files manageable.
+var RECORD_SCALE = 4
+
+// Captures every frame drawn while recording, along with when it was drawn,
+// so animated slides can be saved with their real timing
+type RecordingDisplay struct {
+	SlideId string
+	// Simulated brightness percentage, for previewing night mode
+	Brightness int
+
+	Recording bool
+	Frames    []*image.RGBA
+	Times     []time.Time
+	Lock      sync.Mutex
+}
+
+func NewRecordingDisplay() *RecordingDisplay {
+	d := new(RecordingDisplay)
+	d.Brightness = 100
+	return d
+}
+
+func (d *RecordingDisplay) Initialize() {
+
+}
+
+func (d *RecordingDisplay) Redraw(img *image.RGBA) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	// Ignore anything drawn between recordings
+	if !d.Recording {
+		return
+	}
+	d.Frames = append(d.Frames, ScaleBrightness(img, d.Brightness))
+	d.Times = append(d.Times, clock.Now())
+}
+
+func (d *RecordingDisplay) SetBrightness(percent int) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.Brightness = percent
+}
+
+// Discards anything previously recorded and starts capturing frames
+func (d *RecordingDisplay) StartRecording(s Slide) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.SlideId = reflect.TypeOf(s).Elem().Name()
+	d.Recording = true
+	d.Frames = nil
+	d.Times = nil
+}
+
+// Stops capturing frames and saves them as an animated GIF
+func (d *RecordingDisplay) StopRecording() {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.Recording = false
+	if len(d.Frames) == 0 {
+		log.WithFields(log.Fields{
+			"slide": d.SlideId,
+		}).Warn("Slide drew nothing while recording.")
+		return
+	}
+
+	filename := fmt.Sprintf("render/%s.gif", d.SlideId)
+	err := d.SaveGif(filename, clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithFields(log.Fields{
+		"file":   filename,
+		"frames": len(d.Frames),
+	}).Info("Saved recording of slide.")
+}
+
+// Expects the caller to hold the lock
+func (d *RecordingDisplay) SaveGif(filename string, end time.Time) error {
+	anim := &gif.GIF{}
+	for i, frame := range d.Frames {
+		rendered := RenderLedDots(frame, RECORD_SCALE, false).Image()
+		paletted := image.NewPaletted(rendered.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), rendered, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+
+		// Each frame stays up until the next one was drawn
+		next := end
+		if i+1 < len(d.Times) {
+			next = d.Times[i+1]
+		}
+		anim.Delay = append(anim.Delay, GifDelay(next.Sub(d.Times[i])))
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gif.EncodeAll(f, anim)
+}
+
+// Converts a duration to GIF delay units of 1/100th of a second. Browsers
+// treat very short delays as much longer ones, so there's a lower limit.
+func GifDelay(t time.Duration) int {
+	delay := int(t.Round(10*time.Millisecond) / (10 * time.Millisecond))
+	if delay < 2 {
+		return 2
+	}
+	return delay
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingDisplaySavesGif(t *testing.T) {
+	fake := UseFakeClock(t, time.Date(2021, 12, 20, 10, 30, 0, 0, time.UTC))
+	d := NewRecordingDisplay()
+
+	// Frames drawn before recording starts are ignored
+	d.Redraw(NewBlankImage())
+	d.StartRecording(NewTimeSlide())
+	for _, wait := range []time.Duration{500 * time.Millisecond, time.Second, 0} {
+		d.Redraw(NewBlankImage())
+		fake.Advance(wait)
+	}
+	if d.SlideId != "TimeSlide" {
+		t.Errorf("Got slide ID %q, expected TimeSlide", d.SlideId)
+	}
+
+	filename := filepath.Join(t.TempDir(), "recording.gif")
+	d.Lock.Lock()
+	err := d.SaveGif(filename, clock.Now().Add(2*time.Second))
+	d.Lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 {
+		t.Fatalf("Got %d frames, expected 3", len(anim.Image))
+	}
+	expectedDelays := []int{50, 100, 200}
+	for i, delay := range anim.Delay {
+		if delay != expectedDelays[i] {
+			t.Errorf("Got delay %d for frame %d, expected %d", delay, i, expectedDelays[i])
+		}
+	}
+	b := anim.Image[0].Bounds()
+	if b.Dx() != SCREEN_WIDTH*RECORD_SCALE || b.Dy() != SCREEN_HEIGHT*RECORD_SCALE {
+		t.Errorf("Got %dx%d frames, expected %dx%d", b.Dx(), b.Dy(),
+			SCREEN_WIDTH*RECORD_SCALE, SCREEN_HEIGHT*RECORD_SCALE)
+	}
+}
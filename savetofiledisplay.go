@@ -15,12 +15,18 @@ var RENDER_SCALE = 8
 var DOT_PADDING = 0.75
 var MIN_BRIGHTNESS = uint8(40)
 
+// Frames shown in a transition preview, including the first and last
+var TRANSITION_PREVIEW_FRAMES = 5
+
 type SaveToFileDisplay struct {
 	SlideId string
+	// Simulated brightness percentage, for previewing night mode
+	Brightness int
 }
 
 func NewSaveToFileDisplay() *SaveToFileDisplay {
 	d := new(SaveToFileDisplay)
+	d.Brightness = 100
 	return d
 }
 
@@ -29,9 +35,48 @@ func (d *SaveToFileDisplay) Initialize() {
 }
 
 func (d *SaveToFileDisplay) Redraw(img *image.RGBA) {
+	img = ScaleBrightness(img, d.Brightness)
+	dc := RenderLedDots(img, RENDER_SCALE, DRAW_GRIDLINES)
+
+	filename := fmt.Sprintf("render/%s.png", d.SlideId)
+	err := dc.SavePNG(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithFields(log.Fields{
+		"file": filename,
+	}).Info("Saved rendering of slide.")
+}
+
+// Saves the frames of a transition into the slide in one image, from top to
+// bottom
+func (d *SaveToFileDisplay) SaveTransition(frames []*image.RGBA) {
+	height := SCREEN_HEIGHT * RENDER_SCALE
+	dc := gg.NewContext(SCREEN_WIDTH*RENDER_SCALE, height*len(frames))
+	for i, img := range frames {
+		img = ScaleBrightness(img, d.Brightness)
+		frame := RenderLedDots(img, RENDER_SCALE, false)
+		dc.DrawImage(frame.Image(), 0, i*height)
+	}
+
+	filename := fmt.Sprintf("render/%s-transition.png", d.SlideId)
+	err := dc.SavePNG(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithFields(log.Fields{
+		"file":   filename,
+		"frames": len(frames),
+	}).Info("Saved rendering of transition.")
+}
+
+// Draws each pixel as a round LED so renderings look like the real thing
+func RenderLedDots(img *image.RGBA, scale int, gridlines bool) *gg.Context {
 	// Define the height of the drawing canvas, in real pixels
-	dcWidth := SCREEN_WIDTH * RENDER_SCALE
-	dcHeight := SCREEN_HEIGHT * RENDER_SCALE
+	dcWidth := SCREEN_WIDTH * scale
+	dcHeight := SCREEN_HEIGHT * scale
 
 	dc := gg.NewContext(dcWidth, dcHeight)
 	dc.DrawRectangle(0, 0, float64(dcWidth), float64(dcHeight))
@@ -42,15 +87,15 @@ func (d *SaveToFileDisplay) Redraw(img *image.RGBA) {
 	for j := 0; j < SCREEN_HEIGHT; j++ {
 		for i := 0; i < SCREEN_WIDTH; i++ {
 			dc.DrawCircle(
-				(float64(i)+0.5)*float64(RENDER_SCALE),
-				(float64(j)+0.5)*float64(RENDER_SCALE),
-				(float64(RENDER_SCALE)*DOT_PADDING)/2)
-			dc.SetColor(d.FloorColor(img.RGBAAt(i, j)))
+				(float64(i)+0.5)*float64(scale),
+				(float64(j)+0.5)*float64(scale),
+				(float64(scale)*DOT_PADDING)/2)
+			dc.SetColor(FloorColor(img.RGBAAt(i, j)))
 			dc.Fill()
 		}
 	}
 
-	if DRAW_GRIDLINES {
+	if gridlines {
 		dc.SetRGB(0, 1.0, 1.0)
 
 		// Draw major center lines
@@ -61,10 +106,10 @@ func (d *SaveToFileDisplay) Redraw(img *image.RGBA) {
 		// Draw minor 8-dot grid lines
 		dc.SetLineWidth(0.5)
 		for j := 8; j < SCREEN_HEIGHT; j += 8 {
-			dc.DrawLine(0, float64(j*RENDER_SCALE), float64(dcWidth), float64(j*RENDER_SCALE))
+			dc.DrawLine(0, float64(j*scale), float64(dcWidth), float64(j*scale))
 		}
 		for i := 8; i < SCREEN_WIDTH; i += 8 {
-			dc.DrawLine(float64(i*RENDER_SCALE), 0, float64(i*RENDER_SCALE), float64(dcHeight))
+			dc.DrawLine(float64(i*scale), 0, float64(i*scale), float64(dcHeight))
 		}
 
 		// Put the lines on the canvas
@@ -75,20 +120,16 @@ func (d *SaveToFileDisplay) Redraw(img *image.RGBA) {
 			panic(err)
 		}
 		for i := 0; i < SCREEN_WIDTH; i += 8 {
-			dc.DrawString(fmt.Sprintf("%d", i), float64(i*RENDER_SCALE), float64(8))
+			dc.DrawString(fmt.Sprintf("%d", i), float64(i*scale), float64(8))
 		}
 
 	}
 
-	filename := fmt.Sprintf("render/%s.png", d.SlideId)
-	err := dc.SavePNG(filename)
-	if err != nil {
-		log.Fatal(err)
-	}
+	return dc
+}
 
-	log.WithFields(log.Fields{
-		"file": filename,
-	}).Info("Saved rendering of slide.")
+func (d *SaveToFileDisplay) SetBrightness(percent int) {
+	d.Brightness = percent
 }
 
 func (d *SaveToFileDisplay) SetSlideId(s Slide) {
@@ -96,14 +137,14 @@ func (d *SaveToFileDisplay) SetSlideId(s Slide) {
 }
 
 // Set a minimum (gray) RGB value if none is provided
-func (d *SaveToFileDisplay) FloorColor(c color.RGBA) color.RGBA {
-	r := d.Max(c.R, MIN_BRIGHTNESS)
-	g := d.Max(c.G, MIN_BRIGHTNESS)
-	b := d.Max(c.B, MIN_BRIGHTNESS)
+func FloorColor(c color.RGBA) color.RGBA {
+	r := MaxUint8(c.R, MIN_BRIGHTNESS)
+	g := MaxUint8(c.G, MIN_BRIGHTNESS)
+	b := MaxUint8(c.B, MIN_BRIGHTNESS)
 	return color.RGBA{r, g, b, c.A}
 }
 
-func (d *SaveToFileDisplay) Max(a, b uint8) uint8 {
+func MaxUint8(a, b uint8) uint8 {
 	if a > b {
 		return a
 	}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// Restricts when a slide may be shown. Each of the rules is optional, and
+// when several are given they all need to match.
+type Schedule struct {
+	// Allowed days of the week, indexed by time.Weekday
+	Weekdays [7]bool
+	// Allowed times of day; a window may wrap past midnight
+	Windows []TimeWindow
+	// Allowed calendar ranges, either recurring yearly or on specific dates
+	DateRanges []DateRange
+}
+
+// Minutes since midnight, with End exclusive
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+// Inclusive range of days. If Yearly is set, only month and day are compared.
+type DateRange struct {
+	Start  civil.Date
+	End    civil.Date
+	Yearly bool
+}
+
+var WEEKDAY_NAMES = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Builds a schedule from its config, returning nil if no rules are given
+func ParseSchedule(c ScheduleConfig) (*Schedule, error) {
+	if len(c.Weekdays) == 0 && len(c.Times) == 0 && len(c.Dates) == 0 {
+		return nil, nil
+	}
+
+	sch := new(Schedule)
+
+	if len(c.Weekdays) == 0 {
+		for i := range sch.Weekdays {
+			sch.Weekdays[i] = true
+		}
+	}
+	for _, w := range c.Weekdays {
+		// Accept either a single day or a range like "mon-fri"
+		parts := strings.SplitN(strings.ToLower(w), "-", 2)
+		first, ok := WEEKDAY_NAMES[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", w)
+		}
+		last := first
+		if len(parts) == 2 {
+			last, ok = WEEKDAY_NAMES[parts[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q", w)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			sch.Weekdays[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	for _, t := range c.Times {
+		parts := strings.Split(t, "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("time window %q should look like \"07:00-10:00\"", t)
+		}
+		start, err := ParseTimeOfDay(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParseTimeOfDay(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, fmt.Errorf("time window %q is empty", t)
+		}
+		sch.Windows = append(sch.Windows, TimeWindow{start, end})
+	}
+
+	for _, d := range c.Dates {
+		r, err := ParseDateRange(d)
+		if err != nil {
+			return nil, err
+		}
+		sch.DateRanges = append(sch.DateRanges, r)
+	}
+
+	return sch, nil
+}
+
+// Parses "HH:MM" (or "H:MM") into minutes since midnight. "24:00" is allowed
+// as an end.
+func ParseTimeOfDay(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[0]) < 1 || len(parts[0]) > 2 || len(parts[1]) != 2 ||
+		!isDigits(parts[0]) || !isDigits(parts[1]) {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	h, _ := strconv.Atoi(parts[0])
+	m, _ := strconv.Atoi(parts[1])
+	if m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return h*60 + m, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses "MM-DD..MM-DD" (every year) or "YYYY-MM-DD..YYYY-MM-DD". A single
+// date without ".." matches just that day.
+func ParseDateRange(s string) (DateRange, error) {
+	parts := strings.Split(s, "..")
+	if len(parts) > 2 {
+		return DateRange{}, fmt.Errorf("invalid date range %q", s)
+	}
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+
+	var r DateRange
+	start, startYearly, err := parseScheduleDate(parts[0])
+	if err != nil {
+		return r, err
+	}
+	end, endYearly, err := parseScheduleDate(parts[1])
+	if err != nil {
+		return r, err
+	}
+	if startYearly != endYearly {
+		return r, fmt.Errorf("date range %q mixes yearly and specific dates", s)
+	}
+	if !startYearly && end.Before(start) {
+		return r, fmt.Errorf("date range %q ends before it starts", s)
+	}
+
+	r.Start = start
+	r.End = end
+	r.Yearly = startYearly
+	return r, nil
+}
+
+func parseScheduleDate(s string) (civil.Date, bool, error) {
+	s = strings.TrimSpace(s)
+	if d, err := civil.ParseDate(s); err == nil {
+		return d, false, nil
+	}
+	// Use a leap year so that Feb 29 is accepted
+	d, err := civil.ParseDate("2000-" + s)
+	if err != nil || !d.IsValid() {
+		return civil.Date{}, false, fmt.Errorf("invalid date %q", s)
+	}
+	return d, true, nil
+}
+
+// Whether the slide may be shown at the given time. A nil schedule always
+// allows the slide.
+func (sch *Schedule) IsActive(t time.Time) bool {
+	if sch == nil {
+		return true
+	}
+
+	if !sch.Weekdays[t.Weekday()] {
+		return false
+	}
+
+	if len(sch.Windows) > 0 {
+		minute := t.Hour()*60 + t.Minute()
+		inWindow := false
+		for _, w := range sch.Windows {
+			if w.Contains(minute) {
+				inWindow = true
+				break
+			}
+		}
+		if !inWindow {
+			return false
+		}
+	}
+
+	if len(sch.DateRanges) > 0 {
+		today := civil.DateOf(t)
+		inRange := false
+		for _, r := range sch.DateRanges {
+			if r.Contains(today) {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w TimeWindow) Contains(minute int) bool {
+	if w.Start <= w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	// Window wraps past midnight, e.g. 22:00-02:00
+	return minute >= w.Start || minute < w.End
+}
+
+func (r DateRange) Contains(d civil.Date) bool {
+	if !r.Yearly {
+		return !d.Before(r.Start) && !d.After(r.End)
+	}
+	md := int(d.Month)*100 + d.Day
+	start := int(r.Start.Month)*100 + r.Start.Day
+	end := int(r.End.Month)*100 + r.End.Day
+	if start <= end {
+		return md >= start && md <= end
+	}
+	// Range wraps past new year, e.g. 12-15..01-05
+	return md >= start || md <= end
+}
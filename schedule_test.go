@@ -0,0 +1,183 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestParseTimeOfDay(t *testing.T) {
+	cases := []struct {
+		In     string
+		Minute int
+		Ok     bool
+	}{
+		{"07:00", 7 * 60, true},
+		{"7:05", 7*60 + 5, true},
+		{" 16:30 ", 16*60 + 30, true},
+		{"00:00", 0, true},
+		{"24:00", 24 * 60, true},
+		{"24:01", 0, false},
+		{"12:60", 0, false},
+		{"07:00xyz", 0, false},
+		{"7:5pm", 0, false},
+		{"7:5", 0, false},
+		{"007:00", 0, false},
+		{"-1:00", 0, false},
+		{"+7:00", 0, false},
+		{"07", 0, false},
+		{"07:00:00", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		got, err := ParseTimeOfDay(c.In)
+		if c.Ok && err != nil {
+			t.Errorf("ParseTimeOfDay(%q) failed: %v", c.In, err)
+		} else if !c.Ok && err == nil {
+			t.Errorf("ParseTimeOfDay(%q) = %d, expected an error", c.In, got)
+		} else if c.Ok && got != c.Minute {
+			t.Errorf("ParseTimeOfDay(%q) = %d, expected %d", c.In, got, c.Minute)
+		}
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	cases := []struct {
+		Window TimeWindow
+		Minute int
+		In     bool
+	}{
+		{TimeWindow{7 * 60, 10 * 60}, 7 * 60, true},
+		{TimeWindow{7 * 60, 10 * 60}, 10*60 - 1, true},
+		{TimeWindow{7 * 60, 10 * 60}, 10 * 60, false},
+		{TimeWindow{7 * 60, 10 * 60}, 6 * 60, false},
+		// Wrapping past midnight
+		{TimeWindow{22 * 60, 2 * 60}, 23 * 60, true},
+		{TimeWindow{22 * 60, 2 * 60}, 0, true},
+		{TimeWindow{22 * 60, 2 * 60}, 2*60 - 1, true},
+		{TimeWindow{22 * 60, 2 * 60}, 2 * 60, false},
+		{TimeWindow{22 * 60, 2 * 60}, 12 * 60, false},
+		// Ending at midnight
+		{TimeWindow{22 * 60, 24 * 60}, 24*60 - 1, true},
+		{TimeWindow{22 * 60, 24 * 60}, 0, false},
+		// Start == end is empty
+		{TimeWindow{8 * 60, 8 * 60}, 8 * 60, false},
+		{TimeWindow{8 * 60, 8 * 60}, 20 * 60, false},
+	}
+	for _, c := range cases {
+		if got := c.Window.Contains(c.Minute); got != c.In {
+			t.Errorf("%+v.Contains(%d) = %v, expected %v", c.Window, c.Minute, got, c.In)
+		}
+	}
+}
+
+func TestDateRangeContains(t *testing.T) {
+	winter := DateRange{date(2000, 12, 15), date(2000, 1, 5), true}
+	summer := DateRange{date(2000, 6, 1), date(2000, 8, 31), true}
+	once := DateRange{date(2026, 6, 1), date(2026, 6, 7), false}
+	cases := []struct {
+		Range DateRange
+		Date  civil.Date
+		In    bool
+	}{
+		// Yearly range wrapping past new year
+		{winter, date(2025, 12, 15), true},
+		{winter, date(2025, 12, 31), true},
+		{winter, date(2026, 1, 1), true},
+		{winter, date(2026, 1, 5), true},
+		{winter, date(2026, 1, 6), false},
+		{winter, date(2026, 12, 14), false},
+		{winter, date(2026, 7, 1), false},
+		// Yearly range within a year
+		{summer, date(1999, 6, 1), true},
+		{summer, date(2030, 8, 31), true},
+		{summer, date(2030, 9, 1), false},
+		// Specific dates only match in that year
+		{once, date(2026, 6, 1), true},
+		{once, date(2026, 6, 7), true},
+		{once, date(2026, 6, 8), false},
+		{once, date(2027, 6, 3), false},
+	}
+	for _, c := range cases {
+		if got := c.Range.Contains(c.Date); got != c.In {
+			t.Errorf("%+v.Contains(%v) = %v, expected %v", c.Range, c.Date, got, c.In)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	sch, err := ParseSchedule(ScheduleConfig{})
+	if err != nil || sch != nil {
+		t.Errorf("Expected an empty config to give no schedule, got %+v, %v", sch, err)
+	}
+
+	sch, err = ParseSchedule(ScheduleConfig{
+		Weekdays: []string{"Fri-mon"},
+		Times:    []string{"22:00-02:00"},
+		Dates:    []string{"12-15..01-05"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDays := [7]bool{true, true, false, false, false, true, true}
+	if sch.Weekdays != expectedDays {
+		t.Errorf("Got weekdays %v, expected %v", sch.Weekdays, expectedDays)
+	}
+	if len(sch.Windows) != 1 || sch.Windows[0] != (TimeWindow{22 * 60, 2 * 60}) {
+		t.Errorf("Got windows %+v", sch.Windows)
+	}
+	if len(sch.DateRanges) != 1 || !sch.DateRanges[0].Yearly {
+		t.Errorf("Got date ranges %+v", sch.DateRanges)
+	}
+
+	// 2025-12-31 is a Wednesday, 2026-01-02 is a Friday
+	active := []struct {
+		Time   time.Time
+		Active bool
+	}{
+		{time.Date(2026, 1, 2, 23, 0, 0, 0, time.Local), true},
+		{time.Date(2026, 1, 3, 1, 59, 0, 0, time.Local), true},
+		{time.Date(2026, 1, 3, 2, 0, 0, 0, time.Local), false},
+		{time.Date(2025, 12, 31, 23, 0, 0, 0, time.Local), false},
+		{time.Date(2026, 1, 9, 23, 0, 0, 0, time.Local), false},
+	}
+	for _, c := range active {
+		if got := sch.IsActive(c.Time); got != c.Active {
+			t.Errorf("IsActive(%v) = %v, expected %v", c.Time, got, c.Active)
+		}
+	}
+
+	// Times alone allow every day
+	sch, err = ParseSchedule(ScheduleConfig{Times: []string{"07:00-10:00"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d, ok := range sch.Weekdays {
+		if !ok {
+			t.Errorf("Expected %v to be allowed", time.Weekday(d))
+		}
+	}
+
+	invalid := []ScheduleConfig{
+		{Weekdays: []string{"funday"}},
+		{Weekdays: []string{"mon-someday"}},
+		{Times: []string{"07:00"}},
+		{Times: []string{"07:00-10:00-12:00"}},
+		{Times: []string{"07:00xyz-10:00"}},
+		{Times: []string{"08:00-08:00"}},
+		{Dates: []string{"13-01..13-05"}},
+		{Dates: []string{"12-01..2026-12-05"}},
+		{Dates: []string{"2026-06-07..2026-06-01"}},
+		{Dates: []string{"01-01..02-01..03-01"}},
+	}
+	for _, c := range invalid {
+		if _, err := ParseSchedule(c); err == nil {
+			t.Errorf("Expected %+v to be rejected", c)
+		}
+	}
+}
+
+func date(y int, m time.Month, d int) civil.Date {
+	return civil.Date{Year: y, Month: m, Day: d}
+}
@@ -1,14 +1,85 @@
 package main
 
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"time"
+)
+
 type Slide interface {
-	// Called when slideshow is being started
-	Initialize()
+	// Called when slideshow is being started. Fetches in progress give up
+	// once the context is cancelled.
+	Initialize(ctx context.Context)
 	// Called when slideshow is being stopped
 	Terminate()
-	// Called when slide is brought into view
-	StartDraw(d Display)
-	// Called when a different slide is brought into view
-	StopDraw()
+	// Draws the slide as it is now onto a blank image. Only the compositor
+	// calls this, one frame at a time.
+	Draw(img *image.RGBA)
+	// How often the slide needs redrawing while it's shown. Zero means it's
+	// only drawn when brought into view, or when it has marked itself dirty.
+	GetFrameInterval() time.Duration
 	// Controls whether slide will be skipped in slideshow
 	IsEnabled() bool
 }
+
+// Optionally implemented by slides whose look changes on its own, e.g. when
+// new data arrives, so they can be redrawn between regular frames
+type DirtySlide interface {
+	// Whether the slide has changed since this was last called
+	TakeDirty() bool
+}
+
+// Embedded by slides to implement DirtySlide. Safe to mark from any goroutine.
+type DirtyFlag struct {
+	// 1 when dirty, accessed with sync/atomic
+	Value int32
+}
+
+func (f *DirtyFlag) MarkDirty() {
+	atomic.StoreInt32(&f.Value, 1)
+}
+
+func (f *DirtyFlag) TakeDirty() bool {
+	return atomic.SwapInt32(&f.Value, 0) == 1
+}
+
+// Optionally implemented by slides with an animation that starts over each
+// time they're brought into view
+type AnimatedSlide interface {
+	RestartAnimation()
+}
+
+// Optionally implemented by slides that fetch remote data
+type FetchingSlide interface {
+	// Reports how the slide's most recent data fetches went
+	GetFetchStatus() FetchStatus
+}
+
+type FetchStatus struct {
+	Success bool `json:"success"`
+	// Null if the slide hasn't fetched anything yet
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	// Whether older data is being shown because recent fetches failed
+	Stale bool `json:"stale"`
+}
+
+// Combines the status of several fetchers, which all need to succeed. The
+// combined data is only as recent as the oldest of them.
+func CombineFetchStatus(statuses ...FetchStatus) FetchStatus {
+	combined := FetchStatus{Success: true}
+	for i, st := range statuses {
+		combined.Success = combined.Success && st.Success
+		combined.Stale = combined.Stale || st.Stale
+		if st.ConsecutiveFailures > combined.ConsecutiveFailures {
+			combined.ConsecutiveFailures = st.ConsecutiveFailures
+		}
+		if st.LastSuccess == nil {
+			combined.LastSuccess = nil
+		} else if i == 0 || (combined.LastSuccess != nil && st.LastSuccess.Before(*combined.LastSuccess)) {
+			combined.LastSuccess = st.LastSuccess
+		}
+	}
+	return combined
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+
+	"cloud.google.com/go/civil"
+)
+
+// Builds a slide from its config, returning an error if parameters are invalid
+type SlideFactory func(c SlideConfig) (Slide, error)
+
+var slideFactories map[string]SlideFactory
+
+func RegisterSlideFactory(slideType string, f SlideFactory) {
+	slideFactories[slideType] = f
+}
+
+func InitSlideFactories() {
+
+	// Initialize the map
+	slideFactories = make(map[string]SlideFactory)
+
+	RegisterSlideFactory("TimeSlide", func(c SlideConfig) (Slide, error) {
+		return NewTimeSlide(), nil
+	})
+
+	RegisterSlideFactory("WeatherSlide", func(c SlideConfig) (Slide, error) {
+		office := c.NwsOffice
+		if office == "" {
+			office = NWS_OFFICE
+		}
+		station := c.NwsStation
+		if station == "" {
+			station = NWS_STATION
+		}
+		sl := NewWeatherSlide(office, station)
+		ApplyHttpConfig(c, sl.ObservationsHttpHelper, sl.ForecastHttpHelper)
+		return sl, nil
+	})
+
+	RegisterSlideFactory("MbtaSlide", func(c SlideConfig) (Slide, error) {
+		if c.StationId == "" {
+			return nil, fmt.Errorf("station_id is required")
+		}
+		sl := NewMbtaSlide(c.StationId)
+		ApplyHttpConfig(c, sl.HttpHelper)
+		return sl, nil
+	})
+
+	RegisterSlideFactory("FlightSlide", func(c SlideConfig) (Slide, error) {
+		if len(c.Flights) == 0 {
+			return nil, fmt.Errorf("flights is required")
+		}
+		for date := range c.Flights {
+			if _, err := civil.ParseDate(date); err != nil {
+				return nil, fmt.Errorf("invalid flight date %q", date)
+			}
+		}
+		sl := NewFlightSlide(c.Flights)
+		ApplyHttpConfig(c, sl.HttpHelper)
+		return sl, nil
+	})
+
+	RegisterSlideFactory("CovidSlide", func(c SlideConfig) (Slide, error) {
+		sl := NewCovidSlide()
+		if c.RefreshInterval.Duration > 0 {
+			sl.FetchInterval = c.RefreshInterval.Duration
+		}
+		return sl, nil
+	})
+
+	RegisterSlideFactory("VaccinationSlide", func(c SlideConfig) (Slide, error) {
+		sl := NewVaccinationSlide()
+		ApplyHttpConfig(c, sl.HttpHelper)
+		return sl, nil
+	})
+
+	RegisterSlideFactory("CountdownSlide", func(c SlideConfig) (Slide, error) {
+		if len(c.Events) == 0 {
+			return nil, fmt.Errorf("events is required")
+		}
+		var events []CountdownEvent
+		for _, e := range c.Events {
+			d, err := civil.ParseDate(e.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid event date %q", e.Date)
+			}
+			if e.Label == "" {
+				return nil, fmt.Errorf("event on %s is missing a label", e.Date)
+			}
+			eventColor := color.RGBA{255, 255, 255, 255}
+			if e.Color != "" {
+				if _, err := hex.DecodeString(e.Color); err != nil || len(e.Color) != 6 {
+					return nil, fmt.Errorf("invalid event color %q", e.Color)
+				}
+				eventColor = ColorFromHex(e.Color)
+			}
+			events = append(events, CountdownEvent{
+				date:  d,
+				label: e.Label,
+				color: eventColor,
+			})
+		}
+		return NewCountdownSlide(events), nil
+	})
+
+	RegisterSlideFactory("ChristmasSlide", func(c SlideConfig) (Slide, error) {
+		return NewChristmasSlide(), nil
+	})
+
+	RegisterSlideFactory("NewYearSlide", func(c SlideConfig) (Slide, error) {
+		return NewNewYearSlide(), nil
+	})
+
+	RegisterSlideFactory("StayHomeSlide", func(c SlideConfig) (Slide, error) {
+		return NewStayHomeSlide(), nil
+	})
+
+	RegisterSlideFactory("GlyphTestSlide", func(c SlideConfig) (Slide, error) {
+		switch c.Test {
+		case "", "letters":
+			return NewGlyphTestSlide(TEST_LETTERS), nil
+		case "numsym":
+			return NewGlyphTestSlide(TEST_NUMSYM), nil
+		default:
+			return nil, fmt.Errorf("unknown test %q", c.Test)
+		}
+	})
+
+}
+
+func BuildSlide(c SlideConfig) (Slide, error) {
+	f, ok := slideFactories[c.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown slide type")
+	}
+	if c.RefreshInterval.Duration < 0 {
+		return nil, fmt.Errorf("refresh_interval must not be negative")
+	}
+	if c.MaxDataAge.Duration < 0 {
+		return nil, fmt.Errorf("max_data_age must not be negative")
+	}
+	return f(c)
+}
+
+// Applies the fetch settings shared by slides that use HttpHelper
+func ApplyHttpConfig(c SlideConfig, helpers ...*HttpHelper) {
+	for _, h := range helpers {
+		if c.RefreshInterval.Duration > 0 {
+			h.Config.RefreshInterval = c.RefreshInterval.Duration
+		}
+		if c.MaxDataAge.Duration > 0 {
+			h.Config.MaxDataAge = c.MaxDataAge.Duration
+		}
+	}
+}
@@ -1,148 +1,651 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
-	"os/exec"
+	"reflect"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Slideshow struct {
-	Display         Display
+	// Cancelled when the program is shutting down, which stops everything
+	// the slideshow started
+	Ctx             context.Context
+	Compositor      *Compositor
 	AdvanceInterval time.Duration
-	Slides          []Slide
+	// Used between slides that don't set their own
+	Transition Transition
+	Slides     []*SlideEntry
+	Network    Network
 
 	Running        bool
 	Frozen         bool
 	CurrentSlide   Slide
 	CurrentSlideId int
-	AdvanceTicker  *time.Ticker
+	AdvanceTimer   *time.Timer
+	NextAdvance    time.Time
+	// Cancelled when stopped, which ends the advance timer's goroutine and
+	// everything the slides started
+	RunCtx    context.Context
+	CancelRun context.CancelFunc
+	// Number of complete passes through the slide list since starting
+	Cycle int
+	// Whether the connection probes succeeded at startup
+	Online   bool
+	TimeSync *TimeSyncStatus
+
+	// Guards everything above except Ctx, Compositor, and Network, which don't
+	// change. The advance timer, controller handlers, reloads, and slide
+	// initialization all run on different goroutines.
+	Lock sync.Mutex
+	// Held for the whole of a reload, so two reloads can't both initialize
+	// their own new slides and then swap over each other. Taken before Lock.
+	ReloadLock sync.Mutex
 }
 
-func NewSlideshow(d Display, config *Config) *Slideshow {
+func NewSlideshow(ctx context.Context, d Display, config *Config) *Slideshow {
 	s := new(Slideshow)
-	s.Display = d
+	s.Ctx = ctx
+	s.Compositor = NewCompositor(d)
+	s.Compositor.Start(ctx)
 	s.AdvanceInterval = config.AdvanceInterval
+	s.Transition = config.Transition
 	s.Slides = config.Slides
+	s.Network = config.Network
 	return s
 }
 
-func (s *Slideshow) Start() {
+// Returns false if the slideshow was already running. The welcome slide is
+// shown while waiting for a connection and loading slides, which happens in
+// the background.
+func (s *Slideshow) Start() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Running {
+		return false
+	}
 	s.Running = true
 	s.CurrentSlideId = -1
+	s.Cycle = -1
+	ctx, cancel := context.WithCancel(s.Ctx)
+	s.RunCtx = ctx
+	s.CancelRun = cancel
 
 	// Display the welcome slide while loading
 	s.CurrentSlide = NewWelcomeSlide()
-	s.CurrentSlide.StartDraw(s.Display)
-
-	// Block until all slides have loaded data
-	s.WaitForReadiness()
+	s.Compositor.Show(s.CurrentSlide)
+	go s.Run(ctx)
+	return true
+}
 
-	log.Info("All slides reported readiness.")
+// Waits until the slides are able to load data, then starts the rotation and
+// loads them. Returns early if stopped.
+func (s *Slideshow) Run(ctx context.Context) {
+	s.WaitForReadiness(ctx)
 
-	// Then go to the first slide and run for real
-	s.Advance()
-
-	// Increment the slide number periodically and start/stop drawing
-	s.AdvanceTicker = time.NewTicker(s.AdvanceInterval)
+	// Increment the slide number when the timer fires and start/stop drawing.
+	// Each advance re-arms the timer with the incoming slide's duration.
+	s.Lock.Lock()
+	if ctx.Err() != nil {
+		// Stopped or shut down while waiting, so there's nothing more to do
+		s.Lock.Unlock()
+		return
+	}
+	timer := time.NewTimer(s.AdvanceInterval)
+	timer.Stop()
+	s.AdvanceTimer = timer
+	s.Lock.Unlock()
 	go func() {
-		for range s.AdvanceTicker.C {
-			// Don't advance if the show has been manually frozen
-			if !s.Frozen {
-				s.Advance()
+		for {
+			select {
+			case <-timer.C:
+				s.AdvanceUnlessFrozen()
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
+
+	// Start the rotation, which shows the welcome slide until a slide is ready
+	s.Advance()
+
+	// Each slide joins the rotation once it's ready
+	s.InitializeSlides(ctx, s.GetSlides())
+	log.Info("All slides finished initializing.")
 }
 
 func (s *Slideshow) Advance() {
-	s.CurrentSlide.StopDraw()
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	s.StepLocked(1)
+}
 
-	for {
-		s.CurrentSlideId = (s.CurrentSlideId + 1) % len(s.Slides)
-		s.CurrentSlide = s.Slides[s.CurrentSlideId]
-		// If the slide is enabled, stop the loop
-		if s.CurrentSlide.IsEnabled() {
-			break
+// Used by the advance timer, which shouldn't move on while the show has been
+// manually frozen
+func (s *Slideshow) AdvanceUnlessFrozen() {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Running && !s.Frozen {
+		s.StepLocked(1)
+	}
+}
+
+// Goes back to the previous eligible slide
+func (s *Slideshow) GoBack() {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	s.StepLocked(-1)
+}
+
+// Same as Advance, but expects the caller to hold the lock
+func (s *Slideshow) AdvanceLocked() {
+	s.StepLocked(1)
+}
+
+// Moves forwards (1) or backwards (-1) to the next eligible slide.
+// Expects the caller to hold the lock.
+func (s *Slideshow) StepLocked(direction int) {
+	// Slides limited to every Nth cycle may need several passes to come up,
+	// so look far enough ahead that any eligible slide will be found
+	maxSteps := len(s.Slides)
+	for _, e := range s.Slides {
+		if len(s.Slides)*e.Config.EveryNthCycle > maxSteps {
+			maxSteps = len(s.Slides) * e.Config.EveryNthCycle
+		}
+	}
+
+	now := clock.Now()
+	for i := 0; i < maxSteps; i++ {
+		if direction > 0 {
+			s.CurrentSlideId = (s.CurrentSlideId + 1) % len(s.Slides)
+			if s.CurrentSlideId == 0 {
+				s.Cycle++
+			}
+		} else {
+			if s.CurrentSlideId <= 0 {
+				s.CurrentSlideId = len(s.Slides)
+				s.Cycle--
+			}
+			s.CurrentSlideId--
+		}
+		e := s.Slides[s.CurrentSlideId]
+		// If the slide is loaded, enabled, scheduled, and due this cycle, show it
+		if e.Ready && e.Slide.IsEnabled() && e.Schedule.IsActive(now) && s.IsDueThisCycle(e) {
+			s.CurrentSlide = e.Slide
+			s.Compositor.ShowWithTransition(s.CurrentSlide, e.GetTransition(s.Transition))
+			s.ScheduleAdvance(s.GetDuration(e))
+			return
 		}
-		// Otherwise we loop until we find an enabled slide
-		// This would probably get stuck if no slides are enabled at all
 	}
 
-	s.CurrentSlide.StartDraw(s.Display)
+	// Nothing is eligible right now, so blank the screen and check again
+	// later. Keep saying hello if that's because slides are still loading.
+	log.Debug("No slides eligible to be shown.")
+	if s.IsLoadingLocked() {
+		s.CurrentSlide = NewWelcomeSlide()
+	} else {
+		s.CurrentSlide = NewBlankSlide()
+	}
+	s.Compositor.ShowWithTransition(s.CurrentSlide, s.Transition)
+	s.ScheduleAdvance(s.AdvanceInterval)
+}
+
+// Restarts the advance timer so it fires after the given duration
+func (s *Slideshow) ScheduleAdvance(d time.Duration) {
+	if s.AdvanceTimer == nil {
+		return
+	}
+	s.AdvanceTimer.Stop()
+	s.AdvanceTimer.Reset(d)
+	s.NextAdvance = time.Now().Add(d)
 }
 
-func (s *Slideshow) WaitForReadiness() {
-	// Don't initialize until internet is available
-	WaitForConnection()
+// Jumps straight to the slide with the given ID, even if it would normally
+// be skipped, and shows it for its usual duration
+func (s *Slideshow) ShowSlide(id string) error {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	for i, e := range s.Slides {
+		if e.Id != id {
+			continue
+		}
+		s.CurrentSlideId = i
+		s.CurrentSlide = e.Slide
+		s.Compositor.ShowWithTransition(s.CurrentSlide, e.GetTransition(s.Transition))
+		s.ScheduleAdvance(s.GetDuration(e))
+		return nil
+	}
+	return fmt.Errorf("no slide with ID %q", id)
+}
+
+// How long a slide stays on screen, falling back to the global interval
+func (s *Slideshow) GetDuration(e *SlideEntry) time.Duration {
+	if e.Config.Duration.Duration > 0 {
+		return e.Config.Duration.Duration
+	}
+	return s.AdvanceInterval
+}
+
+// Slides can be configured to only appear on every Nth pass through the list
+func (s *Slideshow) IsDueThisCycle(e *SlideEntry) bool {
+	n := e.Config.EveryNthCycle
+	if n <= 1 {
+		return true
+	}
+	// Going back from the first pass makes the cycle negative, so keep the
+	// remainder positive
+	return (s.Cycle%n+n)%n == 0
+}
+
+func (s *Slideshow) WaitForReadiness(ctx context.Context) {
+	// Don't initialize until internet is available, or it's clearly not
+	// coming back soon
+	online := WaitForConnection(ctx, s.Network.ProbeUrls, s.Network.MaxWait)
+	if ctx.Err() != nil {
+		return
+	}
 
 	// Attempt to update time before displaying anything calculated
-	SyncTime()
+	ts := SyncTime(s.Network.NtpServers)
 
-	// Initialize all slides (attempt fetching initial content)
-	// This call on each slide blocks until request is complete
-	for _, sl := range s.Slides {
-		sl.Initialize()
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	s.Online = online
+	s.TimeSync = ts
+}
+
+// How long to wait for a slide's initial fetch before carrying on without
+// it. The slide still joins the rotation if it finishes later.
+const SLIDE_INIT_TIMEOUT = 30 * time.Second
+
+// Initializes slides in parallel, so one slow feed doesn't hold up the rest.
+// Returns once every slide is ready or has run past the timeout.
+func (s *Slideshow) InitializeSlides(ctx context.Context, entries []*SlideEntry) {
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		// Each slide gets its own context, so removing it stops whatever it
+		// has started so far
+		slideCtx, cancel := context.WithCancel(ctx)
+		s.Lock.Lock()
+		e.Cancel = cancel
+		s.Lock.Unlock()
+		go func(e *SlideEntry) {
+			defer wg.Done()
+			done := make(chan bool)
+			go func() {
+				e.Slide.Initialize(slideCtx)
+				s.MarkReady(ctx, e)
+				close(done)
+			}()
+			timeout := clock.NewTicker(SLIDE_INIT_TIMEOUT)
+			defer timeout.Stop()
+			select {
+			case <-done:
+			case <-timeout.C():
+				log.WithFields(log.Fields{
+					"slide":   e.Id,
+					"timeout": SLIDE_INIT_TIMEOUT,
+				}).Warn("Slide is slow to initialize, continuing without it.")
+			case <-ctx.Done():
+			}
+		}(e)
 	}
+	wg.Wait()
 }
 
-func (s *Slideshow) Stop() {
+// Adds a slide to the rotation, and shows it right away if nothing else is
+// being shown yet. The context is the one the slide was initialized with.
+func (s *Slideshow) MarkReady(ctx context.Context, e *SlideEntry) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	// Stopping resets the slide, so it's loaded again when next started.
+	// Slides initialized by an earlier run, or since removed, stay out.
+	if !s.Running || ctx != s.RunCtx || e.Removed {
+		return
+	}
+	e.Ready = true
+	log.WithFields(log.Fields{
+		"slide": e.Id,
+	}).Debug("Slide ready.")
+
+	if s.Running && !s.Frozen && s.AdvanceTimer != nil && !s.IsShowingSlideLocked() {
+		s.AdvanceLocked()
+	}
+}
+
+// Whether any slide in the rotation hasn't finished initializing.
+// Expects the caller to hold the lock.
+func (s *Slideshow) IsLoadingLocked() bool {
+	for _, e := range s.Slides {
+		if !e.Ready {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the current slide is from the rotation, rather than a placeholder
+// like the welcome or blank slide. Expects the caller to hold the lock.
+func (s *Slideshow) IsShowingSlideLocked() bool {
+	return s.CurrentSlideId >= 0 && s.CurrentSlideId < len(s.Slides) &&
+		s.Slides[s.CurrentSlideId].Slide == s.CurrentSlide
+}
+
+// Returns false if the slideshow was already stopped
+func (s *Slideshow) Stop() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	if !s.Running {
+		return false
+	}
 	s.Running = false
-	s.CurrentSlide.StopDraw()
-	s.AdvanceTicker.Stop()
+	s.CancelRun()
+	// The timer doesn't exist yet if still waiting for a connection
+	if s.AdvanceTimer != nil {
+		s.AdvanceTimer.Stop()
+		s.AdvanceTimer = nil
+	}
+	s.NextAdvance = time.Time{}
 
-	// Stop any slide-level tickers
-	for _, sl := range s.Slides {
-		sl.Terminate()
+	// Stop any slide-level tickers. Slides are initialized again when next
+	// started, so they're out of the rotation until then.
+	for _, e := range s.Slides {
+		e.Slide.Terminate()
+		e.Ready = false
 	}
 
 	// Draw a blank image
-	s.Display.Redraw(NewBlankImage())
+	s.Compositor.Clear()
+	return true
+}
+
+func (s *Slideshow) IsRunning() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return s.Running
+}
+
+func (s *Slideshow) IsFrozen() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return s.Frozen
+}
+
+// Returns the slides currently in the rotation
+func (s *Slideshow) GetSlides() []*SlideEntry {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return s.Slides
 }
 
-func (s *Slideshow) Freeze() {
+// Swaps in the slides from a new config. Slides whose config is unchanged
+// keep running as-is, new slides are initialized before the swap, and slides
+// no longer in the config are terminated.
+func (s *Slideshow) Reload(config *Config) {
+	s.ReloadLock.Lock()
+	defer s.ReloadLock.Unlock()
+
+	// Index the current slides so unchanged ones can be carried over
+	existing := make(map[string][]*SlideEntry)
+	for _, e := range s.GetSlides() {
+		existing[e.Key()] = append(existing[e.Key()], e)
+	}
+
+	var slides []*SlideEntry
+	var added []*SlideEntry
+	ids := make(map[*SlideEntry]string)
+	for _, e := range config.Slides {
+		k := e.Key()
+		if prev := existing[k]; len(prev) > 0 {
+			slides = append(slides, prev[0])
+			existing[k] = prev[1:]
+			// Generated IDs depend on position, so they may have changed
+			ids[prev[0]] = e.Id
+			continue
+		}
+		slides = append(slides, e)
+		added = append(added, e)
+	}
+
+	// Anything not carried over has been removed from the config
+	removed := make(map[*SlideEntry]bool)
+	for _, entries := range existing {
+		for _, e := range entries {
+			removed[e] = true
+		}
+	}
+
+	// Fetch content for new slides before they can be shown. This happens
+	// outside the lock since it blocks until requests complete, or time out.
+	s.Lock.Lock()
+	running, ctx := s.Running, s.RunCtx
+	s.Lock.Unlock()
+	if running {
+		s.InitializeSlides(ctx, added)
+	}
+
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	// The current slide might now be at a different position, or gone
+	var current *SlideEntry
+	if s.CurrentSlideId >= 0 && s.CurrentSlideId < len(s.Slides) {
+		current = s.Slides[s.CurrentSlideId]
+	}
+
+	// Any change to a slide's config, including its duration or schedule,
+	// replaces it, so carried over slides only need their IDs updated
+	for e, id := range ids {
+		e.Id = id
+	}
+	s.Slides = slides
+	s.AdvanceInterval = config.AdvanceInterval
+	s.Transition = config.Transition
+	for e := range removed {
+		e.Removed = true
+		// Stops the slide even if it's still initializing, which
+		// terminating alone can't
+		if e.Cancel != nil {
+			e.Cancel()
+		}
+	}
+
+	if current != nil && removed[current] {
+		// Resume from the slot the removed slide occupied
+		s.CurrentSlideId = min(s.CurrentSlideId, len(s.Slides)) - 1
+		if s.Running {
+			s.AdvanceLocked()
+		}
+	} else if current != nil {
+		for i, e := range s.Slides {
+			if e == current {
+				s.CurrentSlideId = i
+			}
+		}
+	}
+
+	if s.Running {
+		for e := range removed {
+			e.Slide.Terminate()
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"slides":  len(slides),
+		"added":   len(added),
+		"removed": len(removed),
+	}).Info("Reloaded slideshow config.")
+}
+
+// Snapshot of the slideshow state, as reported by the controller
+type SlideshowStatus struct {
+	Running      bool   `json:"running"`
+	Frozen       bool   `json:"frozen"`
+	CurrentSlide string `json:"current_slide"`
+	Online       bool   `json:"online"`
+	// Null until the slideshow has tried to set the clock
+	TimeSync *TimeSyncStatus `json:"time_sync"`
+	// Null when frozen or stopped, since the show won't advance on its own
+	SecondsUntilAdvance *float64      `json:"seconds_until_advance"`
+	Slides              []SlideStatus `json:"slides"`
+}
+
+type SlideStatus struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Current bool   `json:"current"`
+	// Whether the slide has finished initializing and can be shown
+	Ready bool `json:"ready"`
+	// Whether the slide considers itself showable right now
+	Enabled bool `json:"enabled"`
+	// Whether the slide's configured schedule allows it right now
+	Scheduled bool         `json:"scheduled"`
+	Fetch     *FetchStatus `json:"fetch,omitempty"`
+}
+
+func (s *Slideshow) GetStatus() SlideshowStatus {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	status := SlideshowStatus{
+		Running:  s.Running,
+		Frozen:   s.Frozen,
+		Online:   s.Online,
+		TimeSync: s.TimeSync,
+		Slides:   s.GetSlideStatusesLocked(),
+	}
+
+	// The current slide may not be in the rotation (e.g. the welcome slide)
+	if s.CurrentSlide != nil {
+		status.CurrentSlide = reflect.TypeOf(s.CurrentSlide).Elem().Name()
+	}
+	for _, sl := range status.Slides {
+		if sl.Current {
+			status.CurrentSlide = sl.Id
+		}
+	}
+
+	if s.Running && !s.Frozen && !s.NextAdvance.IsZero() {
+		secs := time.Until(s.NextAdvance).Seconds()
+		if secs < 0 {
+			secs = 0
+		}
+		status.SecondsUntilAdvance = &secs
+	}
+	return status
+}
+
+func (s *Slideshow) GetSlideStatuses() []SlideStatus {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	return s.GetSlideStatusesLocked()
+}
+
+// Expects the caller to hold the lock
+func (s *Slideshow) GetSlideStatusesLocked() []SlideStatus {
+	now := clock.Now()
+	statuses := []SlideStatus{}
+	for i, e := range s.Slides {
+		st := SlideStatus{
+			Id:        e.Id,
+			Type:      e.Config.Type,
+			Current:   i == s.CurrentSlideId && e.Slide == s.CurrentSlide,
+			Ready:     e.Ready,
+			Enabled:   e.Slide.IsEnabled(),
+			Scheduled: e.Schedule.IsActive(now),
+		}
+		if fs, ok := e.Slide.(FetchingSlide); ok {
+			f := fs.GetFetchStatus()
+			st.Fetch = &f
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// Returns false if the slideshow was already frozen
+func (s *Slideshow) Freeze() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if s.Frozen {
+		return false
+	}
 	s.Frozen = true
+	return true
 }
 
-func (s *Slideshow) Unfreeze() {
+// Returns false if the slideshow wasn't frozen
+func (s *Slideshow) Unfreeze() bool {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if !s.Frozen {
+		return false
+	}
 	s.Frozen = false
-	s.Advance()
+	if s.Running {
+		s.StepLocked(1)
+	}
+	return true
 }
 
-// Checks for internet periodically, not returning until connected.
-func WaitForConnection() {
+// How long each probe gets before it counts as failed
+const CONNECTION_PROBE_TIMEOUT = 5 * time.Second
+
+// Checks for internet periodically, not returning until connected, the max
+// wait has passed, or the context is cancelled. Returns whether there's a
+// connection.
+func WaitForConnection(ctx context.Context, probeUrls []string, maxWait time.Duration) bool {
+	start := time.Now()
 	c := 1
 	for {
-		if ConnectionPresent() {
+		if ConnectionPresent(ctx, probeUrls) {
 			log.WithFields(log.Fields{
 				"checks": c,
 			}).Info("Internet connection present.")
-			return
+			return true
+		}
+		if maxWait > 0 && time.Since(start) >= maxWait {
+			log.WithFields(log.Fields{
+				"checks": c,
+			}).Warn("No internet connection, starting in offline mode.")
+			return false
+		}
+		select {
+		case <-time.After(1 * time.Second):
+		case <-ctx.Done():
+			return false
 		}
-		time.Sleep(1 * time.Second)
 		c++
 	}
 }
 
-// Synchronizes with a NTP server, in case Pi lost power for a while
-func SyncTime() {
-	cmd := exec.Command("/usr/sbin/ntpdate", "-s", "time.google.com")
-	err := cmd.Run()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Warning("Failed NTP time synchronization.")
-	}
-}
-
-// Sanity check for internet access. Not bulletproof but works.
-func ConnectionPresent() bool {
-	_, err := http.Get("http://clients3.google.com/generate_204")
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Debug("Connection failed.")
+// Sanity check for internet access. Not bulletproof but works. Any of the
+// probes responding is enough.
+func ConnectionPresent(ctx context.Context, probeUrls []string) bool {
+	client := &http.Client{Timeout: CONNECTION_PROBE_TIMEOUT}
+	for _, u := range probeUrls {
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			continue
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"url":   u,
+				"error": err,
+			}).Debug("Connection failed.")
+			continue
+		}
+		res.Body.Close()
+		return true
 	}
-	return err == nil
+	return false
 }
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlideshowGoBackBeforeFirstCycle(t *testing.T) {
+	entries := BuildFakeSlides(3)
+	entries[2].Config.EveryNthCycle = 2
+	for _, e := range entries {
+		e.Ready = true
+	}
+	// Stepping doesn't need the frame clock running
+	s := &Slideshow{
+		Ctx:        context.Background(),
+		Compositor: NewCompositor(NewCaptureDisplay()),
+		Slides:     entries,
+	}
+
+	// Every other pass through the list skips the last slide, whichever
+	// direction the passes go in
+	var shown []int
+	for i := 0; i < 7; i++ {
+		s.GoBack()
+		shown = append(shown, s.CurrentSlideId)
+	}
+	expected := []int{1, 0, 2, 1, 0, 1, 0}
+	for i := range expected {
+		if shown[i] != expected[i] {
+			t.Fatalf("Went back through %v, expected %v", shown, expected)
+		}
+	}
+	if s.Cycle != -3 {
+		t.Errorf("Expected to be 3 cycles back, got %d", s.Cycle)
+	}
+}
+
+// Takes as long to initialize as the test wants
+type BlockingSlide struct {
+	FakeSlide
+	Release chan bool
+}
+
+func (sl *BlockingSlide) Initialize(ctx context.Context) {
+	select {
+	case <-sl.Release:
+	case <-ctx.Done():
+	}
+}
+
+// Polls until the condition holds, for things that happen on other goroutines
+func WaitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlideshowInitializesSlidesInParallel(t *testing.T) {
+	startTime, err := ParseClockTime(GOLDEN_DEFAULT_TIME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := UseFakeClock(t, startTime)
+
+	entries := BuildFakeSlides(2)
+	slow := &BlockingSlide{Release: make(chan bool)}
+	entries[1].Slide = slow
+	s := &Slideshow{
+		Ctx:            context.Background(),
+		Compositor:     NewCompositor(NewCaptureDisplay()),
+		Slides:         entries,
+		Running:        true,
+		RunCtx:         context.Background(),
+		CurrentSlideId: -1,
+	}
+	isReady := func(e *SlideEntry) func() bool {
+		return func() bool {
+			s.Lock.Lock()
+			defer s.Lock.Unlock()
+			return e.Ready
+		}
+	}
+
+	done := make(chan bool)
+	go func() {
+		s.InitializeSlides(context.Background(), entries)
+		close(done)
+	}()
+	WaitFor(t, "the fast slide to be ready", isReady(entries[0]))
+
+	// Give up on the slow slide once both have been waited on for long enough
+	WaitFor(t, "both timeouts to start", func() bool {
+		fake.Lock.Lock()
+		defer fake.Lock.Unlock()
+		return len(fake.Tickers) == 2
+	})
+	select {
+	case <-done:
+		t.Fatal("Finished initializing while a slide was still loading")
+	default:
+	}
+	fake.Advance(SLIDE_INIT_TIMEOUT)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Still waiting on the slow slide after the timeout")
+	}
+
+	// Only ready slides are shown
+	for i := 0; i < 3; i++ {
+		s.Advance()
+		if s.CurrentSlide != entries[0].Slide {
+			t.Fatalf("Expected only the ready slide to be shown, got slide %d", s.CurrentSlideId)
+		}
+	}
+	close(slow.Release)
+	WaitFor(t, "the slow slide to be ready", isReady(entries[1]))
+	s.Advance()
+	if s.CurrentSlide != slow {
+		t.Errorf("Expected the slow slide to join the rotation once ready, got slide %d", s.CurrentSlideId)
+	}
+}
+
+func TestSlideshowStartAndStop(t *testing.T) {
+	// Nothing answers the probe, so the show waits on a connection
+	s := NewSlideshow(context.Background(), NewCaptureDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides:          BuildFakeSlides(2),
+		Network: Network{
+			ProbeUrls: []string{"http://127.0.0.1:1"},
+			MaxWait:   time.Minute,
+		},
+	})
+	started := time.Now()
+	s.Start()
+	if time.Since(started) > time.Second {
+		t.Error("Expected starting not to wait for a connection")
+	}
+	s.Stop()
+
+	// Once stopped, slides are out of the rotation until loaded again
+	s = NewSlideshow(context.Background(), NewCaptureDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides:          BuildFakeSlides(2),
+		Network:         StartProbeServer(t),
+	})
+	s.Start()
+	for _, e := range s.GetSlides() {
+		WaitFor(t, "slides to be ready", func() bool {
+			s.Lock.Lock()
+			defer s.Lock.Unlock()
+			return e.Ready
+		})
+	}
+	s.Stop()
+	for _, e := range s.GetSlides() {
+		if e.Ready {
+			t.Errorf("Expected %s not to be ready after stopping", e.Id)
+		}
+	}
+}
+
+// Holds every request open until it's cancelled
+type HangingTransport struct {
+	Received chan bool
+	Once     sync.Once
+}
+
+func (h *HangingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.Once.Do(func() { close(h.Received) })
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestSlideshowStopDuringInitialize(t *testing.T) {
+	// Terminating a slide that never started shouldn't fail either
+	NewCovidSlide().Terminate()
+
+	transport := &HangingTransport{Received: make(chan bool)}
+	sl := NewCovidSlide()
+	sl.Client = &http.Client{Transport: transport}
+	s := NewSlideshow(context.Background(), NewCaptureDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides: []*SlideEntry{{
+			Id:     "Covid",
+			Config: SlideConfig{Type: "CovidSlide"},
+			Slide:  sl,
+		}},
+		Network: StartProbeServer(t),
+	})
+	s.Start()
+	<-transport.Received
+	s.Stop()
+
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	if sl.FetchTicker != nil {
+		t.Error("Expected the refresh ticker to be stopped")
+	}
+}
+
+// Says when the slide it wraps has finished initializing
+type InitSignalSlide struct {
+	Slide
+	Initialized chan bool
+}
+
+func (sl *InitSignalSlide) Initialize(ctx context.Context) {
+	sl.Slide.Initialize(ctx)
+	close(sl.Initialized)
+}
+
+func TestSlideshowStopDuringWeatherInitialize(t *testing.T) {
+	transport := &HangingTransport{Received: make(chan bool)}
+	sl := NewWeatherSlide(NWS_OFFICE, NWS_STATION)
+	sl.ObservationsHttpHelper.Client = &http.Client{Transport: transport}
+	sl.ForecastHttpHelper.Client = &http.Client{Transport: transport}
+	signal := &InitSignalSlide{Slide: sl, Initialized: make(chan bool)}
+	s := NewSlideshow(context.Background(), NewCaptureDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides: []*SlideEntry{{
+			Id:     "Weather",
+			Config: SlideConfig{Type: "WeatherSlide"},
+			Slide:  signal,
+		}},
+		Network: StartProbeServer(t),
+	})
+	s.Start()
+	<-transport.Received
+	s.Stop()
+	<-signal.Initialized
+
+	// Neither helper is left looking like it's running, so both fetch again
+	// when next started
+	for _, h := range GetHttpHelpers(sl) {
+		WaitFor(t, h.Config.SlideId+" to stop", func() bool {
+			h.Lock.Lock()
+			defer h.Lock.Unlock()
+			return h.RefreshTicker == nil
+		})
+	}
+}
+
+func TestSlideshowIgnoresStaleInitializations(t *testing.T) {
+	entries := BuildFakeSlides(2)
+	s := &Slideshow{
+		Ctx:        context.Background(),
+		Compositor: NewCompositor(NewCaptureDisplay()),
+		Slides:     entries,
+		Running:    true,
+		RunCtx:     context.Background(),
+	}
+
+	// Left over from a run that has since been stopped
+	old, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.MarkReady(old, entries[0])
+	// Taken out of the show by a reload
+	entries[1].Removed = true
+	s.MarkReady(s.RunCtx, entries[1])
+	for _, e := range entries {
+		if e.Ready {
+			t.Errorf("Expected %s not to be marked ready", e.Id)
+		}
+	}
+}
+
+// Hands over the context it's initialized with, then waits until it's done
+type ContextSlide struct {
+	FakeSlide
+	Ctx chan context.Context
+}
+
+func (sl *ContextSlide) Initialize(ctx context.Context) {
+	sl.Ctx <- ctx
+	<-ctx.Done()
+}
+
+func TestSlideshowReloadDuringInitialize(t *testing.T) {
+	sl := &ContextSlide{Ctx: make(chan context.Context, 1)}
+	s := NewSlideshow(context.Background(), NewCaptureDisplay(), &Config{
+		AdvanceInterval: time.Minute,
+		Slides: []*SlideEntry{{
+			Id:     "Loading",
+			Config: SlideConfig{Type: "Loading"},
+			Slide:  sl,
+		}},
+		Network: StartProbeServer(t),
+	})
+	s.Start()
+	defer s.Stop()
+	ctx := <-sl.Ctx
+
+	// Removing the slide stops it, even though it hasn't finished loading
+	s.Reload(&Config{
+		AdvanceInterval: time.Minute,
+		Slides:          BuildFakeSlides(1),
+	})
+	if ctx.Err() == nil {
+		t.Error("Expected the removed slide's context to be cancelled")
+	}
+}
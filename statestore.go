@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Where slides keep data that should survive a restart. Nothing is saved if
+// this is nil.
+var stateStore *StateStore
+
+// Saves values as JSON, one file per key, so the board can show the last
+// known data at boot before anything has been fetched
+type StateStore struct {
+	Dir  string
+	Lock sync.Mutex
+}
+
+func NewStateStore(dir string) *StateStore {
+	st := new(StateStore)
+	st.Dir = dir
+	return st
+}
+
+func (st *StateStore) GetFilename(key string) string {
+	return filepath.Join(st.Dir, key+".json")
+}
+
+// Fills in v with the value saved for the key. Returns whether there was one.
+func (st *StateStore) Load(key string, v interface{}) bool {
+	if st == nil {
+		return false
+	}
+	st.Lock.Lock()
+	defer st.Lock.Unlock()
+
+	b, err := ioutil.ReadFile(st.GetFilename(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithFields(log.Fields{
+				"key":   key,
+				"error": err,
+			}).Warn("Could not read saved state.")
+		}
+		return false
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err,
+		}).Warn("Ignoring unreadable saved state.")
+		return false
+	}
+	return true
+}
+
+func (st *StateStore) Save(key string, v interface{}) {
+	if st == nil {
+		return
+	}
+	st.Lock.Lock()
+	defer st.Lock.Unlock()
+
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = os.MkdirAll(st.Dir, 0755)
+	}
+	if err == nil {
+		// Write then rename, so losing power mid-write doesn't leave a
+		// truncated file behind
+		filename := st.GetFilename(key)
+		err = ioutil.WriteFile(filename+".tmp", b, 0644)
+		if err == nil {
+			err = os.Rename(filename+".tmp", filename)
+		}
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err,
+		}).Warn("Could not save state.")
+	}
+}
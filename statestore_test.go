@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestStateStoreRoundTrip(t *testing.T) {
+	st := NewStateStore(t.TempDir() + "/state")
+	var v map[string]int
+	if st.Load("Test", &v) {
+		t.Error("Expected nothing to load before anything was saved")
+	}
+
+	st.Save("Test", map[string]int{"a": 1})
+	if !st.Load("Test", &v) || v["a"] != 1 {
+		t.Errorf("Got %v, expected the saved value", v)
+	}
+	// The temporary file is renamed into place
+	if _, err := os.Stat(st.GetFilename("Test") + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file left behind, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(st.GetFilename("Test"), []byte(`{"a":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if st.Load("Test", &v) {
+		t.Error("Expected a corrupt file to be ignored")
+	}
+
+	// Without a store, nothing is saved or loaded
+	var none *StateStore
+	none.Save("Test", v)
+	if none.Load("Test", &v) {
+		t.Error("Expected nothing to load without a store")
+	}
+}
+
+func TestCovidSlideRestoresState(t *testing.T) {
+	defer func(st *StateStore) { stateStore = st }(stateStore)
+	stateStore = NewStateStore(t.TempDir())
+	UseFakeClock(t, time.Date(2021, 12, 20, 10, 30, 0, 0, time.UTC))
+
+	yesterday := civil.DateOf(clock.Now().AddDate(0, 0, -1))
+	old := civil.DateOf(clock.Now().AddDate(0, 0, -HISTORICAL_COVID_DAYS-5))
+	sl := NewCovidSlide()
+	sl.UsData.Totals[yesterday] = 1000
+	sl.UsData.Totals[old] = 10
+	sl.MaData.Totals[yesterday] = 100
+	sl.SaveState()
+
+	restored := NewCovidSlide()
+	if !restored.LoadState() {
+		t.Fatal("Expected saved totals to be enough to draw")
+	}
+	if restored.UsData.Totals[yesterday] != 1000 || restored.MaData.Totals[yesterday] != 100 {
+		t.Errorf("Got %v and %v, expected the saved totals", restored.UsData.Totals, restored.MaData.Totals)
+	}
+	if _, ok := restored.UsData.Totals[old]; ok {
+		t.Error("Expected days too old to graph not to be saved")
+	}
+
+	// Totals from too long ago aren't enough to draw
+	UseFakeClock(t, time.Date(2021, 12, 25, 10, 30, 0, 0, time.UTC))
+	if NewCovidSlide().LoadState() {
+		t.Error("Expected out of date totals not to be enough to draw")
+	}
+}
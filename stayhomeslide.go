@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
@@ -18,7 +19,7 @@ func NewStayHomeSlide() *StayHomeSlide {
 	return sl
 }
 
-func (sl *StayHomeSlide) Initialize() {
+func (sl *StayHomeSlide) Initialize(ctx context.Context) {
 
 }
 
@@ -26,12 +27,8 @@ func (sl *StayHomeSlide) Terminate() {
 
 }
 
-func (sl *StayHomeSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *StayHomeSlide) StopDraw() {
-
+func (sl *StayHomeSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *StayHomeSlide) IsEnabled() bool {
@@ -91,7 +88,7 @@ func (sl *StayHomeSlide) Draw(img *image.RGBA) {
 
 func (sl *StayHomeSlide) GetDayCount() int {
 	start := time.Date(2020, time.March, 10, 0, 0, 0, 0, time.Local)
-	return int(math.Ceil(time.Since(start).Hours()/24.0)) - 1
+	return int(math.Ceil(clock.Now().Sub(start).Hours()/24.0)) - 1
 }
 
 func Min(a, b int) int {
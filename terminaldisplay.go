@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sync"
+)
+
+// Draws the matrix in a terminal, for developing slides without hardware.
+// Each character cell shows two pixels, stacked with the upper half block
+// character, using 24-bit color escape codes.
+type TerminalDisplay struct {
+	Out        io.Writer
+	Brightness int
+	LastImage  *image.RGBA
+	Lock       sync.Mutex
+}
+
+func NewTerminalDisplay() *TerminalDisplay {
+	d := new(TerminalDisplay)
+	d.Out = os.Stdout
+	d.Brightness = 100
+	return d
+}
+
+func (d *TerminalDisplay) Initialize() {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	// Clear the screen and hide the cursor
+	fmt.Fprint(d.Out, "\x1b[2J\x1b[?25l")
+}
+
+func (d *TerminalDisplay) Redraw(img *image.RGBA) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.LastImage = img
+	d.Render()
+}
+
+func (d *TerminalDisplay) SetBrightness(percent int) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.Brightness = percent
+	if d.LastImage != nil {
+		d.Render()
+	}
+}
+
+func (d *TerminalDisplay) Close() {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	// Show the cursor again, below the last frame
+	fmt.Fprint(d.Out, "\x1b[?25h")
+}
+
+// Expects the caller to hold the lock
+func (d *TerminalDisplay) Render() {
+	img := ScaleBrightness(d.LastImage, d.Brightness)
+
+	// Build the whole frame first so it's written in one go, which avoids
+	// flicker. Drawing starts from the top left to overwrite the last frame.
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	for j := 0; j < SCREEN_HEIGHT; j += 2 {
+		for i := 0; i < SCREEN_WIDTH; i++ {
+			top := img.RGBAAt(i, j)
+			bottom := img.RGBAAt(i, j+1)
+			fmt.Fprintf(&buf, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀",
+				top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		buf.WriteString("\x1b[0m\n")
+	}
+	d.Out.Write(buf.Bytes())
+}
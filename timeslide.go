@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"strings"
@@ -8,7 +9,6 @@ import (
 )
 
 type TimeSlide struct {
-	RedrawTicker *time.Ticker
 }
 
 func NewTimeSlide() *TimeSlide {
@@ -16,7 +16,7 @@ func NewTimeSlide() *TimeSlide {
 	return sl
 }
 
-func (sl *TimeSlide) Initialize() {
+func (sl *TimeSlide) Initialize(ctx context.Context) {
 
 }
 
@@ -24,12 +24,8 @@ func (sl *TimeSlide) Terminate() {
 
 }
 
-func (sl *TimeSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEverySecond(d, sl.Draw)
-}
-
-func (sl *TimeSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *TimeSlide) GetFrameInterval() time.Duration {
+	return 1 * time.Second
 }
 
 func (sl *TimeSlide) IsEnabled() bool {
@@ -40,7 +36,7 @@ func (sl *TimeSlide) Draw(img *image.RGBA) {
 	white := color.RGBA{255, 255, 255, 255}
 	yellow := color.RGBA{255, 255, 0, 255}
 
-	t := time.Now()
+	t := clock.Now()
 	d0 := strings.ToUpper(t.Format("Monday"))
 	d1 := strings.ToUpper(t.Format("January 2"))
 	t0 := t.Format("3:04 PM")
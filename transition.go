@@ -0,0 +1,117 @@
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+)
+
+// Blends the outgoing slide's last frame into the incoming one. Progress
+// runs from 0 (all outgoing) to 1 (all incoming).
+type TransitionEffect func(from, to *image.RGBA, progress float64) *image.RGBA
+
+// Effects that can be named in the config file
+var TRANSITION_EFFECTS = map[string]TransitionEffect{
+	"push":      PushTransition,
+	"wipe":      WipeTransition,
+	"crossfade": CrossfadeTransition,
+	"dissolve":  DissolveTransition,
+}
+
+const TRANSITION_DEFAULT_DURATION = 400 * time.Millisecond
+
+// How often frames are drawn during a transition, faster than the usual
+// compositor tick so the motion looks smooth
+const TRANSITION_FRAME_INTERVAL = 20 * time.Millisecond
+
+// How to move from one slide to the next. The zero value cuts straight over.
+type Transition struct {
+	Effect   TransitionEffect
+	Duration time.Duration
+}
+
+func (t Transition) IsCut() bool {
+	return t.Effect == nil || t.Duration <= 0
+}
+
+// Evenly spaced frames from the transition, including both ends, for
+// previewing it without a display
+func (t Transition) Frames(from, to *image.RGBA, n int) []*image.RGBA {
+	var frames []*image.RGBA
+	for i := 0; i < n; i++ {
+		progress := 1.0
+		if n > 1 {
+			progress = float64(i) / float64(n-1)
+		}
+		frames = append(frames, t.Effect(from, to, progress))
+	}
+	return frames
+}
+
+// The incoming slide slides in from the right, pushing the outgoing one off
+// to the left
+func PushTransition(from, to *image.RGBA, progress float64) *image.RGBA {
+	img := NewBlankImage()
+	offset := int(math.Round(progress * SCREEN_WIDTH))
+	for j := 0; j < SCREEN_HEIGHT; j++ {
+		for i := 0; i < SCREEN_WIDTH; i++ {
+			if i < SCREEN_WIDTH-offset {
+				img.SetRGBA(i, j, from.RGBAAt(i+offset, j))
+			} else {
+				img.SetRGBA(i, j, to.RGBAAt(i-SCREEN_WIDTH+offset, j))
+			}
+		}
+	}
+	return img
+}
+
+// The incoming slide is revealed from the top down
+func WipeTransition(from, to *image.RGBA, progress float64) *image.RGBA {
+	img := NewBlankImage()
+	edge := int(math.Round(progress * SCREEN_HEIGHT))
+	for j := 0; j < SCREEN_HEIGHT; j++ {
+		src := from
+		if j < edge {
+			src = to
+		}
+		for i := 0; i < SCREEN_WIDTH; i++ {
+			img.SetRGBA(i, j, src.RGBAAt(i, j))
+		}
+	}
+	return img
+}
+
+// Each pixel fades from its outgoing color to its incoming one
+func CrossfadeTransition(from, to *image.RGBA, progress float64) *image.RGBA {
+	img := NewBlankImage()
+	for i := 0; i < len(img.Pix); i++ {
+		a := float64(from.Pix[i])
+		b := float64(to.Pix[i])
+		img.Pix[i] = uint8(math.Round(a + (b-a)*progress))
+	}
+	return img
+}
+
+// Pixels switch over one at a time in a scattered order
+func DissolveTransition(from, to *image.RGBA, progress float64) *image.RGBA {
+	img := NewBlankImage()
+	for j := 0; j < SCREEN_HEIGHT; j++ {
+		for i := 0; i < SCREEN_WIDTH; i++ {
+			if PixelNoise(i, j) < progress {
+				img.SetRGBA(i, j, to.RGBAAt(i, j))
+			} else {
+				img.SetRGBA(i, j, from.RGBAAt(i, j))
+			}
+		}
+	}
+	return img
+}
+
+// A value in [0, 1) that looks random but is fixed for each pixel, so a
+// dissolve goes the same way every time
+func PixelNoise(x, y int) float64 {
+	h := uint32(x)*374761393 + uint32(y)*668265263
+	h = (h ^ (h >> 13)) * 1274126177
+	h ^= h >> 16
+	return float64(h) / (1 << 32)
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"image"
 	"image/color"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -19,6 +21,10 @@ type VaccinationSlide struct {
 	AzData DailyData
 
 	HttpHelper *HttpHelper
+	// Marked when new data is parsed, so it's shown straight away
+	DirtyFlag
+	// Guards the data, which parsing replaces with updated copies
+	Lock sync.Mutex
 }
 
 func NewVaccinationSlide() *VaccinationSlide {
@@ -36,26 +42,26 @@ func NewVaccinationSlide() *VaccinationSlide {
 	return sl
 }
 
-func (sl *VaccinationSlide) Initialize() {
-	sl.HttpHelper.StartLoop()
+func (sl *VaccinationSlide) Initialize(ctx context.Context) {
+	sl.HttpHelper.StartLoop(ctx)
 }
 
 func (sl *VaccinationSlide) Terminate() {
 	sl.HttpHelper.StopLoop()
 }
 
-func (sl *VaccinationSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *VaccinationSlide) StopDraw() {
-
+func (sl *VaccinationSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *VaccinationSlide) IsEnabled() bool {
 	return true
 }
 
+func (sl *VaccinationSlide) GetFetchStatus() FetchStatus {
+	return sl.HttpHelper.GetFetchStatus()
+}
+
 func (sl *VaccinationSlide) Parse(respBytes []byte) bool {
 	r := csv.NewReader(bytes.NewReader(respBytes))
 	rows, err := r.ReadAll()
@@ -66,8 +72,13 @@ func (sl *VaccinationSlide) Parse(respBytes []byte) bool {
 		return false
 	}
 
+	// Earlier totals are kept, so start from copies of them
+	sl.Lock.Lock()
+	us, ma, az := sl.UsData.Copy(), sl.MaData.Copy(), sl.AzData.Copy()
+	sl.Lock.Unlock()
+
 	// We won't draw data before sl.point
-	minDrawDate := civil.DateOf(time.Now().AddDate(0, 0, -HISTORICAL_COVID_DAYS))
+	minDrawDate := civil.DateOf(clock.Now().AddDate(0, 0, -HISTORICAL_COVID_DAYS))
 
 	dateCol := -1
 	peopleVaccinatedCol := -1
@@ -125,26 +136,28 @@ func (sl *VaccinationSlide) Parse(respBytes []byte) bool {
 		count := int(n)
 
 		if row[1] == "Massachusetts" {
-			sl.MaData.Totals[d] = count
+			ma.Totals[d] = count
 		}
 		if row[1] == "Arizona" {
-			sl.AzData.Totals[d] = count
+			az.Totals[d] = count
 		}
 		if row[1] == "United States" {
-			sl.UsData.Totals[d] = count
+			us.Totals[d] = count
 		}
 	}
 
-	sl.UsData = CalculateDiffs(sl.UsData)
-	sl.MaData = CalculateDiffs(sl.MaData)
-	sl.AzData = CalculateDiffs(sl.AzData)
-
+	sl.Lock.Lock()
+	sl.UsData = CalculateDiffs(us)
+	sl.MaData = CalculateDiffs(ma)
+	sl.AzData = CalculateDiffs(az)
+	sl.Lock.Unlock()
+	sl.MarkDirty()
 	return true
 }
 
 func (sl *VaccinationSlide) Draw(img *image.RGBA) {
 	// Stop immediately if we have errors
-	if !sl.HttpHelper.LastFetchSuccess {
+	if !sl.HttpHelper.HasData() {
 		DrawError(img, "Covid Vaccination", "Missing data.")
 		return
 	}
@@ -152,8 +165,16 @@ func (sl *VaccinationSlide) Draw(img *image.RGBA) {
 	green := color.RGBA{0, 255, 0, 255}
 	WriteString(img, "COVID-19 VACCINATIONS", green, ALIGN_CENTER, 63, 0)
 
+	sl.Lock.Lock()
+	us, ma, az := sl.UsData, sl.MaData, sl.AzData
+	sl.Lock.Unlock()
+
 	yellow := color.RGBA{255, 255, 0, 255}
-	DrawDataRow(img, 8, sl.UsData, yellow)
-	DrawDataRow(img, 16, sl.MaData, yellow)
-	DrawDataRow(img, 24, sl.AzData, yellow)
+	DrawDataRow(img, 8, us, yellow)
+	DrawDataRow(img, 16, ma, yellow)
+	DrawDataRow(img, 24, az, yellow)
+
+	if sl.HttpHelper.IsStale() {
+		DrawStaleMarker(img)
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,19 +12,23 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type WeatherSlide struct {
+	NwsOffice    string
+	NwsStation   string
 	Weather      WeatherData
 	WeatherIcons map[string]*image.RGBA
 
 	ObservationsHttpHelper   *HttpHelper
 	ForecastHttpHelper       *HttpHelper
 	HourlyForecastHttpHelper *HttpHelper
-	RedrawTicker             *time.Ticker
+	// Guards Weather, which both fetchers fill in parts of
+	Lock sync.Mutex
 }
 
 type WeatherData struct {
@@ -41,7 +46,7 @@ type WeatherData struct {
 	Forecast2LowTemp  int
 }
 
-// Latitude/longitude values for API requests
+// Default latitude/longitude values for API requests
 // Obtained using https://api.weather.gov/points/42.3643,-71.0854
 const NWS_OFFICE = "BOX/69,76"
 const NWS_STATION = "KBOS"
@@ -92,8 +97,10 @@ var WEATHER_API_ICON_MAP = map[string]string{
 	// "cold":            "",                // Cold
 }
 
-func NewWeatherSlide() *WeatherSlide {
+func NewWeatherSlide(office, station string) *WeatherSlide {
 	sl := new(WeatherSlide)
+	sl.NwsOffice = office
+	sl.NwsStation = station
 	sl.ObservationsHttpHelper = NewHttpHelper(HttpConfig{
 		SlideId:            "WeatherSlide-Observations",
 		RefreshInterval:    5 * time.Minute,
@@ -109,9 +116,9 @@ func NewWeatherSlide() *WeatherSlide {
 	return sl
 }
 
-func (sl *WeatherSlide) Initialize() {
-	sl.ObservationsHttpHelper.StartLoop()
-	sl.ForecastHttpHelper.StartLoop()
+func (sl *WeatherSlide) Initialize(ctx context.Context) {
+	sl.ObservationsHttpHelper.StartLoop(ctx)
+	sl.ForecastHttpHelper.StartLoop(ctx)
 }
 
 func (sl *WeatherSlide) Terminate() {
@@ -119,28 +126,30 @@ func (sl *WeatherSlide) Terminate() {
 	sl.ForecastHttpHelper.StopLoop()
 }
 
-func (sl *WeatherSlide) StartDraw(d Display) {
-	sl.RedrawTicker = DrawEverySecond(d, sl.Draw)
-}
-
-func (sl *WeatherSlide) StopDraw() {
-	sl.RedrawTicker.Stop()
+func (sl *WeatherSlide) GetFrameInterval() time.Duration {
+	return 1 * time.Second
 }
 
 func (sl *WeatherSlide) IsEnabled() bool {
 	return true // Always enabled
 }
 
-func (sl *WeatherSlide) BuildObservationsUrl() (*http.Request, error) {
-	return sl.BuildUrl(fmt.Sprintf("https://api.weather.gov/stations/%s/observations/latest", NWS_STATION))
+func (sl *WeatherSlide) GetFetchStatus() FetchStatus {
+	return CombineFetchStatus(
+		sl.ObservationsHttpHelper.GetFetchStatus(),
+		sl.ForecastHttpHelper.GetFetchStatus())
 }
 
-func (sl *WeatherSlide) BuildForecastUrl() (*http.Request, error) {
-	return sl.BuildUrl(fmt.Sprintf("https://api.weather.gov/gridpoints/%s/forecast", NWS_OFFICE))
+func (sl *WeatherSlide) BuildObservationsUrl(ctx context.Context) (*http.Request, error) {
+	return sl.BuildUrl(ctx, fmt.Sprintf("https://api.weather.gov/stations/%s/observations/latest", sl.NwsStation))
 }
 
-func (sl *WeatherSlide) BuildUrl(url string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (sl *WeatherSlide) BuildForecastUrl(ctx context.Context) (*http.Request, error) {
+	return sl.BuildUrl(ctx, fmt.Sprintf("https://api.weather.gov/gridpoints/%s/forecast", sl.NwsOffice))
+}
+
+func (sl *WeatherSlide) BuildUrl(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +170,7 @@ func (sl *WeatherSlide) ParseObservations(respBytes []byte) bool {
 	}
 
 	t, err := time.Parse(time.RFC3339, respData.Timestamp)
-	if err != nil || time.Since(t) > (6*time.Hour) {
+	if err != nil || clock.Now().Sub(t) > (6*time.Hour) {
 		log.WithFields(log.Fields{
 			"Timestamp": respData.Timestamp,
 		}).Warn("Invalid last update time for observations.")
@@ -169,8 +178,13 @@ func (sl *WeatherSlide) ParseObservations(respBytes []byte) bool {
 	}
 
 	tempInCelsius := float64(respData.Temperature.Value)
-	sl.Weather.CurrentTemp = int((tempInCelsius * (9 / 5.0)) + 32.0)
-	sl.Weather.CurrentIcon = sl.GetIcon(respData.Icon)
+	temp := int((tempInCelsius * (9 / 5.0)) + 32.0)
+	icon := sl.GetIcon(respData.Icon)
+
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.Weather.CurrentTemp = temp
+	sl.Weather.CurrentIcon = icon
 	return true
 }
 
@@ -185,7 +199,7 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 	}
 
 	t, err := time.Parse(time.RFC3339, respData.UpdateTime)
-	if err != nil || time.Since(t) > (6*time.Hour) {
+	if err != nil || clock.Now().Sub(t) > (6*time.Hour) {
 		log.WithFields(log.Fields{
 			"UpdateTime": respData.UpdateTime,
 		}).Warn("Invalid last update time for forecast.")
@@ -197,7 +211,9 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 		panic(err)
 	}
 
-	fTonightEndTime := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day()+1, 6, 0, 0, 0, tz)
+	// Only the forecast fields are filled in here
+	var w WeatherData
+	fTonightEndTime := time.Date(clock.Now().Year(), clock.Now().Month(), clock.Now().Day()+1, 6, 0, 0, 0, tz)
 	fTonight := sl.GetForecastWithEndTime(fTonightEndTime, respData.Periods)
 	if fTonight == nil {
 		log.WithFields(log.Fields{
@@ -206,8 +222,8 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 		return false
 	}
 	// If before 6 PM, show forecast for full day. Otherwise only use nightly forecast.
-	if time.Now().Hour() < 18 {
-		fTodayEndTime := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 18, 0, 0, 0, tz)
+	if clock.Now().Hour() < 18 {
+		fTodayEndTime := time.Date(clock.Now().Year(), clock.Now().Month(), clock.Now().Day(), 18, 0, 0, 0, tz)
 		fToday := sl.GetForecastWithEndTime(fTodayEndTime, respData.Periods)
 		if fToday == nil {
 			log.WithFields(log.Fields{
@@ -215,16 +231,16 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 			}).Warn("Could not find forecast with expected end time.")
 			return false
 		}
-		sl.Weather.Forecast1HighTemp = fToday.Temperature
-		sl.Weather.Forecast1Icon = sl.GetIcon(fToday.Icon)
+		w.Forecast1HighTemp = fToday.Temperature
+		w.Forecast1Icon = sl.GetIcon(fToday.Icon)
 	} else {
-		sl.Weather.Forecast1HighTemp = 0
-		sl.Weather.Forecast1Icon = sl.GetIcon(fTonight.Icon)
+		w.Forecast1HighTemp = 0
+		w.Forecast1Icon = sl.GetIcon(fTonight.Icon)
 	}
-	sl.Weather.Forecast1Weekday = time.Now().Weekday()
-	sl.Weather.Forecast1LowTemp = fTonight.Temperature
+	w.Forecast1Weekday = clock.Now().Weekday()
+	w.Forecast1LowTemp = fTonight.Temperature
 
-	fTomorrowEndTime := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day()+1, 18, 0, 0, 0, tz)
+	fTomorrowEndTime := time.Date(clock.Now().Year(), clock.Now().Month(), clock.Now().Day()+1, 18, 0, 0, 0, tz)
 	fTomorrow := sl.GetForecastWithEndTime(fTomorrowEndTime, respData.Periods)
 	if fTomorrow == nil {
 		log.WithFields(log.Fields{
@@ -232,7 +248,7 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 		}).Warn("Could not find forecast with expected end time.")
 		return false
 	}
-	fTomorrowNightEndTime := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day()+2, 6, 0, 0, 0, tz)
+	fTomorrowNightEndTime := time.Date(clock.Now().Year(), clock.Now().Month(), clock.Now().Day()+2, 6, 0, 0, 0, tz)
 	fTomorrowNight := sl.GetForecastWithEndTime(fTomorrowNightEndTime, respData.Periods)
 	if fTomorrowNight == nil {
 		log.WithFields(log.Fields{
@@ -241,14 +257,31 @@ func (sl *WeatherSlide) ParseForecast(respBytes []byte) bool {
 		return false
 	}
 
-	sl.Weather.Forecast2Weekday = time.Now().Add(time.Hour * 24).Weekday()
-	sl.Weather.Forecast2HighTemp = fTomorrow.Temperature
-	sl.Weather.Forecast2LowTemp = fTomorrowNight.Temperature
-	sl.Weather.Forecast2Icon = sl.GetIcon(fTomorrow.Icon)
-
+	w.Forecast2Weekday = clock.Now().Add(time.Hour * 24).Weekday()
+	w.Forecast2HighTemp = fTomorrow.Temperature
+	w.Forecast2LowTemp = fTomorrowNight.Temperature
+	w.Forecast2Icon = sl.GetIcon(fTomorrow.Icon)
+
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	sl.Weather.Forecast1Weekday = w.Forecast1Weekday
+	sl.Weather.Forecast1Icon = w.Forecast1Icon
+	sl.Weather.Forecast1HighTemp = w.Forecast1HighTemp
+	sl.Weather.Forecast1LowTemp = w.Forecast1LowTemp
+	sl.Weather.Forecast2Weekday = w.Forecast2Weekday
+	sl.Weather.Forecast2Icon = w.Forecast2Icon
+	sl.Weather.Forecast2HighTemp = w.Forecast2HighTemp
+	sl.Weather.Forecast2LowTemp = w.Forecast2LowTemp
 	return true
 }
 
+// Icons aren't changed once created, so the copy can be used freely
+func (sl *WeatherSlide) GetWeather() WeatherData {
+	sl.Lock.Lock()
+	defer sl.Lock.Unlock()
+	return sl.Weather
+}
+
 func (sl *WeatherSlide) GetForecastWithEndTime(expectedEndTime time.Time, periods []WeatherGovForecastPeriod) *WeatherGovForecastPeriod {
 	for _, period := range periods {
 		t, _ := time.Parse(time.RFC3339, period.EndTime)
@@ -312,28 +345,33 @@ func (sl *WeatherSlide) GetIcon(url string) *image.RGBA {
 
 func (sl *WeatherSlide) Draw(img *image.RGBA) {
 	// Stop immediately if we have errors
-	if !sl.ObservationsHttpHelper.LastFetchSuccess || !sl.ForecastHttpHelper.LastFetchSuccess {
+	if !sl.ObservationsHttpHelper.HasData() || !sl.ForecastHttpHelper.HasData() {
 		DrawError(img, "Weather", "No data.")
 		return
 	}
 
+	w := sl.GetWeather()
 	yellow := color.RGBA{255, 255, 0, 255}
 	aqua := color.RGBA{0, 255, 255, 255}
 
-	sl.DrawWeatherBox(img, 21, "NOW", fmt.Sprintf("%d°", sl.Weather.CurrentTemp), yellow, sl.Weather.CurrentIcon)
+	sl.DrawWeatherBox(img, 21, "NOW", fmt.Sprintf("%d°", w.CurrentTemp), yellow, w.CurrentIcon)
 
-	forecast1Label := strings.ToUpper(sl.Weather.Forecast1Weekday.String()[0:3])
-	forecast1BottomText := fmt.Sprintf("%d°/%d°", sl.Weather.Forecast1HighTemp, sl.Weather.Forecast1LowTemp)
+	forecast1Label := strings.ToUpper(w.Forecast1Weekday.String()[0:3])
+	forecast1BottomText := fmt.Sprintf("%d°/%d°", w.Forecast1HighTemp, w.Forecast1LowTemp)
 	// If high temp is zero, that means it wasn't set and we should only show nightly forecast.
 	// Yes technically there's a bug where an actual zero-degree day wouldn't show up correctly.
-	if sl.Weather.Forecast1HighTemp == 0 {
-		forecast1BottomText = fmt.Sprintf("%d°", sl.Weather.Forecast1LowTemp)
+	if w.Forecast1HighTemp == 0 {
+		forecast1BottomText = fmt.Sprintf("%d°", w.Forecast1LowTemp)
 	}
-	sl.DrawWeatherBox(img, 63, forecast1Label, forecast1BottomText, aqua, sl.Weather.Forecast1Icon)
+	sl.DrawWeatherBox(img, 63, forecast1Label, forecast1BottomText, aqua, w.Forecast1Icon)
 
-	forecast2Label := strings.ToUpper(sl.Weather.Forecast2Weekday.String()[0:3])
-	forecast2BottomText := fmt.Sprintf("%d°/%d°", sl.Weather.Forecast2HighTemp, sl.Weather.Forecast2LowTemp)
-	sl.DrawWeatherBox(img, 105, forecast2Label, forecast2BottomText, aqua, sl.Weather.Forecast2Icon)
+	forecast2Label := strings.ToUpper(w.Forecast2Weekday.String()[0:3])
+	forecast2BottomText := fmt.Sprintf("%d°/%d°", w.Forecast2HighTemp, w.Forecast2LowTemp)
+	sl.DrawWeatherBox(img, 105, forecast2Label, forecast2BottomText, aqua, w.Forecast2Icon)
+
+	if sl.ObservationsHttpHelper.IsStale() || sl.ForecastHttpHelper.IsStale() {
+		DrawStaleMarker(img)
+	}
 }
 
 func (sl *WeatherSlide) DrawWeatherBox(img *image.RGBA, centerX int, dateText, temperatureText string, dateColor color.RGBA, icon *image.RGBA) {
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"image"
 	"image/color"
+	"time"
 )
 
 type WelcomeSlide struct {
@@ -13,7 +15,7 @@ func NewWelcomeSlide() *WelcomeSlide {
 	return sl
 }
 
-func (sl *WelcomeSlide) Initialize() {
+func (sl *WelcomeSlide) Initialize(ctx context.Context) {
 	// sl.won't ever get called since sl.slide isn't in the main rotation.
 }
 
@@ -21,12 +23,8 @@ func (sl *WelcomeSlide) Terminate() {
 	// sl.won't ever get called since sl.slide isn't in the main rotation.
 }
 
-func (sl *WelcomeSlide) StartDraw(d Display) {
-	DrawOnce(d, sl.Draw)
-}
-
-func (sl *WelcomeSlide) StopDraw() {
-
+func (sl *WelcomeSlide) GetFrameInterval() time.Duration {
+	return 0
 }
 
 func (sl *WelcomeSlide) IsEnabled() bool {